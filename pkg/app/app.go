@@ -2,10 +2,10 @@ package app
 
 import (
 	"context"
-	"math"
 	"net/http"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/go-faster/errors"
 	"github.com/povilasv/prommod"
@@ -14,8 +14,13 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/Educentr/go-project-starter-runtime/pkg/app/metrics"
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/ratelimit"
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/resourceguard"
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/tracing"
+	"github.com/Educentr/go-project-starter-runtime/pkg/authz"
 	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
 	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
 )
 
 // Все Init флаги вынести в пакет ready и на основе их сделать готовность приложения
@@ -64,20 +69,77 @@ type App struct {
 
 	// Метрики
 	metrics *prometheus.Registry
+
+	// Защита от нехватки памяти и горутин, опционально
+	guard *resourceguard.Guard
+
+	// Настройки GOMAXPROCS и GOMEMLIMIT из cgroup, опционально
+	limitsCfg *RuntimeLimitsConfig
+
+	// Жёсткий лимит на остановку каждого компонента
+	shutdownTimeout time.Duration
+
+	// Движок политик авторизации, опционально
+	authz *authz.Engine
+
+	// Ограничитель частоты запросов, опционально
+	limiter *ratelimit.Limiter
+
+	// Провайдер трассировки, опционально
+	tracer *tracing.Provider
+}
+
+// Option configures App in New
+type Option func(*App)
+
+// WithShutdownTimeout overrides the hard limit of graceful stop for each component (30s by default)
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(a *App) {
+		a.shutdownTimeout = timeout
+	}
+}
+
+// WithResourceGuard enables the resource guard. The guard is started when the
+// application becomes ready and is stopped first during graceful shutdown.
+func WithResourceGuard(cfg resourceguard.Config) Option {
+	return func(a *App) {
+		a.guard = resourceguard.New(cfg)
+	}
+}
+
+// WithAuthz makes e the default engine of authz.Require and registers its metrics
+func WithAuthz(e *authz.Engine) Option {
+	return func(a *App) {
+		a.authz = e
+		authz.SetDefault(e)
+	}
+}
+
+// WithRateLimiter registers metrics of the limiter, transports apply it by
+// l.Middleware or l.Telegram
+func WithRateLimiter(l *ratelimit.Limiter) Option {
+	return func(a *App) {
+		a.limiter = l
+	}
 }
 
 type EmptyUserSetFunc struct{}
 
 func (u *EmptyUserSetFunc) SetFunc(_ context.Context, _ *App) error { return nil }
 
+// UnimplementedAuthorizer accepts every request. It intentionally doesn't
+// implement ds.GRPCAuthorizer and ds.TelegramAuthorizer: gRPC calls and
+// Telegram updates are passed to AuthRest (see serviceauth.AuthGRPC), so
+// authorizers embedding it and overriding AuthRest don't stay permissive there.
 type UnimplementedAuthorizer struct{}
 
 func (u *UnimplementedAuthorizer) Init(_ context.Context, _ []ds.Runnable, _ *prometheus.Registry) (ds.Authorizer, error) {
 	return u, nil
 }
 
+// AuthRest returns an anonymous actor
 func (u *UnimplementedAuthorizer) AuthRest(r *http.Request) (ds.Actor, error) {
-	return &actor.Actor{ID: math.MaxInt64}, nil
+	return actor.NewAnonymous(), nil
 }
 
 func (u *UnimplementedAuthorizer) CheckCSRF(r *http.Request) (bool, error) {
@@ -93,13 +155,25 @@ var (
 	errTransportAlreadyInit = errors.New("transport already initialized")
 	errWorkerAlreadyInit    = errors.New("worker already initialized")
 	errServiceEmpty         = errors.New("service is empty")
+	errTracingAlreadyInit   = errors.New("tracing already initialized")
 )
 
-func New(ctx context.Context, serviceName, name string, info *ds.AppInfo) (*App, error) {
+func New(ctx context.Context, serviceName, name string, info *ds.AppInfo, opts ...Option) (*App, error) {
 	app := &App{
-		info:        info,
-		name:        name,
-		serviceName: serviceName,
+		info:            info,
+		name:            name,
+		serviceName:     serviceName,
+		shutdownTimeout: gracefulShutdownTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(app)
+	}
+
+	if app.limitsCfg != nil {
+		if err := app.applyRuntimeLimits(); err != nil {
+			return nil, errors.Wrap(err, "can't apply runtime limits")
+		}
 	}
 
 	return app, nil
@@ -124,6 +198,41 @@ func (a *App) InitMetrics(ctx context.Context) error {
 		prommod.NewCollector("server"),
 	)
 
+	a.metrics.MustRegister(reqctx.BudgetCollectors()...)
+
+	if a.guard != nil {
+		a.metrics.MustRegister(a.guard.Collectors()...)
+	}
+
+	if a.limitsCfg != nil {
+		a.metrics.MustRegister(runtimeLimitsCollectors(nameForMetric)...)
+	}
+
+	if a.authz != nil {
+		a.metrics.MustRegister(a.authz.Collectors()...)
+	}
+
+	if a.limiter != nil {
+		a.metrics.MustRegister(a.limiter.Collectors()...)
+	}
+
+	return nil
+}
+
+// InitTracing sets up the global TracerProvider with resource attributes of
+// AppInfo. Pending spans are flushed during graceful stop after drivers.
+func (a *App) InitTracing(ctx context.Context, cfg tracing.Config) error {
+	if a.tracer != nil {
+		return errTracingAlreadyInit
+	}
+
+	tracer, err := tracing.InitTracing(ctx, a.serviceName+"-"+a.name, a.info, cfg)
+	if err != nil {
+		return errors.Wrap(err, "can't initialize tracing")
+	}
+
+	a.tracer = tracer
+
 	return nil
 }
 
@@ -299,15 +408,35 @@ func (a *App) Run(ctx context.Context) error {
 
 	// помечаем, что приложение запустилось
 	a.ready.Store(true)
+
+	if a.guard != nil {
+		a.guard.Start(&a.ready)
+	}
+
 	<-ctx.Done()
 
 	return a.gracefulStop(ctx)
 }
 
+// Stop cancels the stop context, Run returns after graceful shutdown is complete
 func (a *App) Stop() error {
+	if a.ctxStop != nil {
+		a.ctxStop()
+	}
+
 	return nil
 }
 
+// IsReady reports whether the application is ready to serve clients
+func (a *App) IsReady() bool {
+	return a.ready.Load()
+}
+
+// ResourceGuard returns the resource guard or nil if it is not enabled
+func (a *App) ResourceGuard() *resourceguard.Guard {
+	return a.guard
+}
+
 // GetMetrics returns the prometheus registry for activerecord initialization
 func (a *App) GetMetrics() *prometheus.Registry {
 	return a.metrics
@@ -0,0 +1,241 @@
+package apptest
+
+import (
+	"context"
+	"net/url"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app"
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+)
+
+// Faults injected into a fake component
+type Faults struct {
+	// InitErr is returned from Init
+	InitErr error
+	// InitializationErr is returned from Initialization (transports and workers only)
+	InitializationErr error
+	// RunErr is returned through errgroup right after Run
+	RunErr error
+	// RunPanic makes Run panic with this value
+	RunPanic any
+	// HangGracefulStop makes GracefulStop return a channel that is never closed
+	HangGracefulStop bool
+	// GracefulStopErr is returned from GracefulStop
+	GracefulStopErr error
+	// ShutdownErr is returned from Shutdown
+	ShutdownErr error
+}
+
+// runnable is the common part of fake components implementing ds.OnlyRunnable
+type runnable struct {
+	name   string
+	rec    *Recorder
+	faults Faults
+
+	mu       sync.Mutex
+	running  bool
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newRunnable(rec *Recorder, name string, faults Faults) runnable {
+	return runnable{
+		name:   name,
+		rec:    rec,
+		faults: faults,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+func (r *runnable) Name() string {
+	return r.name
+}
+
+func (r *runnable) Run(ctx context.Context, errGr *errgroup.Group) {
+	r.rec.Record(r.name, MethodRun)
+
+	if r.faults.RunPanic != nil {
+		panic(r.faults.RunPanic)
+	}
+
+	r.mu.Lock()
+	r.running = true
+	r.mu.Unlock()
+
+	errGr.Go(func() error {
+		defer close(r.done)
+
+		if r.faults.RunErr != nil {
+			return r.faults.RunErr
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-r.stop:
+		}
+
+		return nil
+	})
+}
+
+func (r *runnable) GracefulStop(_ context.Context) (<-chan struct{}, error) {
+	r.rec.Record(r.name, MethodGracefulStop)
+
+	if r.faults.GracefulStopErr != nil {
+		return nil, r.faults.GracefulStopErr
+	}
+
+	if r.faults.HangGracefulStop {
+		return make(chan struct{}), nil
+	}
+
+	r.stopOnce.Do(func() { close(r.stop) })
+
+	r.mu.Lock()
+	running := r.running
+	r.mu.Unlock()
+
+	if !running {
+		stopped := make(chan struct{})
+		close(stopped)
+
+		return stopped, nil
+	}
+
+	return r.done, nil
+}
+
+func (r *runnable) Shutdown(_ context.Context) error {
+	r.rec.Record(r.name, MethodShutdown)
+	r.stopOnce.Do(func() { close(r.stop) })
+
+	return r.faults.ShutdownErr
+}
+
+// Driver is a fake ds.Runnable
+type Driver struct {
+	runnable
+}
+
+var _ ds.Runnable = (*Driver)(nil)
+
+// NewDriver creates a fake driver recording calls into rec
+func NewDriver(rec *Recorder, name string, faults Faults) *Driver {
+	return &Driver{runnable: newRunnable(rec, name, faults)}
+}
+
+func (d *Driver) Init(_ context.Context, _ string, _ ds.ServerBucket, _ *prometheus.Registry) error {
+	d.rec.Record(d.name, MethodInit)
+
+	return d.faults.InitErr
+}
+
+// runnableService is the common part of fake transports and workers
+type runnableService struct {
+	runnable
+}
+
+func (s *runnableService) Init(_ context.Context, _, _ string, _ *prometheus.Registry, _ ds.IService) error {
+	s.rec.Record(s.name, MethodInit)
+
+	return s.faults.InitErr
+}
+
+func (s *runnableService) Initialization(_ context.Context) error {
+	s.rec.Record(s.name, MethodInitialization)
+
+	return s.faults.InitializationErr
+}
+
+// Transport is a fake ds.RunnableService used as transport
+type Transport struct {
+	runnableService
+}
+
+var _ ds.RunnableService = (*Transport)(nil)
+
+// NewTransport creates a fake transport recording calls into rec
+func NewTransport(rec *Recorder, name string, faults Faults) *Transport {
+	return &Transport{runnableService{runnable: newRunnable(rec, name, faults)}}
+}
+
+// Worker is a fake ds.RunnableService used as worker
+type Worker struct {
+	runnableService
+}
+
+var _ ds.RunnableService = (*Worker)(nil)
+
+// NewWorker creates a fake worker recording calls into rec
+func NewWorker(rec *Recorder, name string, faults Faults) *Worker {
+	return &Worker{runnableService{runnable: newRunnable(rec, name, faults)}}
+}
+
+// ServiceName is the name under which the fake service records its calls
+const ServiceName = "service"
+
+// Service is a fake ds.IService
+type Service struct {
+	rec *Recorder
+
+	InitErr       error
+	BeforeRunErr  error
+	Authorizer    ds.Authorizer
+	bucket        ds.ServerBucket
+	hitInfoCalled int
+	mu            sync.Mutex
+}
+
+var _ ds.IService = (*Service)(nil)
+
+// NewService creates a fake service recording calls into rec
+func NewService(rec *Recorder) *Service {
+	return &Service{rec: rec, Authorizer: &app.UnimplementedAuthorizer{}}
+}
+
+func (s *Service) InitService(_ context.Context, _ []ds.Runnable, bucket ds.ServerBucket, _ *prometheus.Registry) error {
+	s.rec.Record(ServiceName, MethodInitService)
+
+	s.mu.Lock()
+	s.bucket = bucket
+	s.mu.Unlock()
+
+	return s.InitErr
+}
+
+func (s *Service) HitInfo(_ context.Context, _ string, _ *url.URL, _ int, _ int, _ string, _ string, _ string, _ string, _ float64) {
+	s.mu.Lock()
+	s.hitInfoCalled++
+	s.mu.Unlock()
+}
+
+// HitInfoCalls returns number of HitInfo calls
+func (s *Service) HitInfoCalls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.hitInfoCalled
+}
+
+func (s *Service) BeforeRunHook(_ context.Context) error {
+	s.rec.Record(ServiceName, MethodBeforeRunHook)
+
+	return s.BeforeRunErr
+}
+
+func (s *Service) GetBucket() ds.ServerBucket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.bucket
+}
+
+func (s *Service) GetAuthorizer() ds.Authorizer {
+	return s.Authorizer
+}
@@ -0,0 +1,180 @@
+package apptest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-faster/errors"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app"
+)
+
+const (
+	defaultStartTimeout = 5 * time.Second
+	defaultStopTimeout  = 5 * time.Second
+	readyPollInterval   = 5 * time.Millisecond
+)
+
+var (
+	errNotReady     = errors.New("app is not ready before timeout")
+	errStopTimeout  = errors.New("app is not stopped before timeout")
+	errStoppedEarly = errors.New("app stopped before it became ready")
+)
+
+// Harness runs an App in background
+type Harness struct {
+	App *app.App
+
+	stopTimeout time.Duration
+
+	done     chan struct{}
+	err      error
+	stopOnce sync.Once
+	stopErr  error
+}
+
+type startConfig struct {
+	startTimeout time.Duration
+	stopTimeout  time.Duration
+}
+
+// StartOption configures Start
+type StartOption func(*startConfig)
+
+// WithStartTimeout limits waiting for readiness
+func WithStartTimeout(timeout time.Duration) StartOption {
+	return func(c *startConfig) {
+		c.startTimeout = timeout
+	}
+}
+
+// WithStopTimeout limits waiting for Run to return after Stop
+func WithStopTimeout(timeout time.Duration) StartOption {
+	return func(c *startConfig) {
+		c.stopTimeout = timeout
+	}
+}
+
+// Start initializes and runs a in background, waits for readiness and
+// registers stop in t.Cleanup. The test fails immediately if the app can't start.
+func Start(t testing.TB, a *app.App, opts ...StartOption) *Harness {
+	t.Helper()
+
+	h, err := StartE(t, a, opts...)
+	if err != nil {
+		t.Fatalf("apptest: can't start app: %v", err)
+	}
+
+	return h
+}
+
+// StartE is like Start but returns error instead of failing the test.
+// Panics in App.Run are recovered and returned as errors.
+func StartE(t testing.TB, a *app.App, opts ...StartOption) (*Harness, error) {
+	t.Helper()
+
+	cfg := startConfig{
+		startTimeout: defaultStartTimeout,
+		stopTimeout:  defaultStopTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	h := &Harness{
+		App:         a,
+		stopTimeout: cfg.stopTimeout,
+		done:        make(chan struct{}),
+	}
+
+	ctx := a.InitStop(context.Background())
+
+	if err := a.Init(ctx); err != nil {
+		_ = a.Stop()
+		return nil, errors.Wrap(err, "init")
+	}
+
+	go h.run(ctx)
+
+	// Run errors are expected in fault injection tests, only a stuck app fails the test
+	t.Cleanup(func() {
+		if err := h.Stop(); errors.Is(err, errStopTimeout) {
+			t.Errorf("apptest: %v", err)
+		}
+	})
+
+	if err := h.waitReady(cfg.startTimeout); err != nil {
+		return h, err
+	}
+
+	return h, nil
+}
+
+func (h *Harness) run(ctx context.Context) {
+	defer close(h.done)
+
+	defer func() {
+		if r := recover(); r != nil {
+			h.err = fmt.Errorf("app panicked: %v", r)
+		}
+	}()
+
+	h.err = h.App.Run(ctx)
+}
+
+func (h *Harness) waitReady(timeout time.Duration) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+
+	for !h.App.IsReady() {
+		select {
+		case <-h.done:
+			if h.err != nil {
+				return h.err
+			}
+
+			return errStoppedEarly
+		case <-deadline.C:
+			return errNotReady
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+// Done is closed when App.Run returns
+func (h *Harness) Done() <-chan struct{} {
+	return h.done
+}
+
+// Err returns the result of App.Run, valid after Done is closed
+func (h *Harness) Err() error {
+	<-h.done
+
+	return h.err
+}
+
+// Stop stops the app and waits for Run to return. It is safe to call Stop
+// several times, subsequent calls return the result of the first one.
+func (h *Harness) Stop() error {
+	h.stopOnce.Do(func() {
+		_ = h.App.Stop()
+
+		select {
+		case <-h.done:
+			h.stopErr = h.err
+		case <-time.After(h.stopTimeout):
+			h.stopErr = errStopTimeout
+		}
+	})
+
+	return h.stopErr
+}
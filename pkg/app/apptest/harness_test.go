@@ -0,0 +1,104 @@
+package apptest_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app"
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/apptest"
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/tracing"
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+)
+
+func newApp(t *testing.T, rec *apptest.Recorder, driver, transport, worker apptest.Faults) *app.App {
+	t.Helper()
+
+	a, err := app.New(context.Background(), "svc", "test", ds.NewAppInfo("test"), app.WithShutdownTimeout(50*time.Millisecond))
+	require.NoError(t, err)
+
+	require.NoError(t, a.SetDriver(apptest.NewDriver(rec, "db", driver)))
+	require.NoError(t, a.SetTransport(apptest.NewTransport(rec, "http", transport)))
+	require.NoError(t, a.SetWorker(apptest.NewWorker(rec, "cron", worker)))
+	require.NoError(t, a.SetService(apptest.NewService(rec)))
+
+	return a
+}
+
+func TestLifecycleOrder(t *testing.T) {
+	rec := apptest.NewRecorder()
+	h := apptest.Start(t, newApp(t, rec, apptest.Faults{}, apptest.Faults{}, apptest.Faults{}))
+
+	assert.True(t, h.App.IsReady())
+	rec.AssertOrder(t,
+		"db.Init", "service.InitService", "http.Init", "cron.Init", "cron.Initialization",
+		"db.Run", "service.BeforeRunHook", "http.Run", "cron.Run",
+	)
+
+	require.NoError(t, h.Stop())
+	assert.False(t, h.App.IsReady())
+	rec.AssertShutdownOrder(t, "http", "cron", "db")
+	assert.Empty(t, rec.Calls(apptest.MethodShutdown))
+}
+
+func TestInitFailure(t *testing.T) {
+	rec := apptest.NewRecorder()
+	errInit := errors.New("init failed")
+
+	_, err := apptest.StartE(t, newApp(t, rec, apptest.Faults{InitErr: errInit}, apptest.Faults{}, apptest.Faults{}))
+	require.ErrorIs(t, err, errInit)
+	assert.False(t, rec.Called(apptest.ServiceName, apptest.MethodInitService))
+}
+
+func TestHangingGracefulStopIsShutdown(t *testing.T) {
+	rec := apptest.NewRecorder()
+	h := apptest.Start(t, newApp(t, rec, apptest.Faults{}, apptest.Faults{HangGracefulStop: true}, apptest.Faults{}))
+
+	require.NoError(t, h.Stop())
+	rec.AssertOrder(t, "http.GracefulStop", "http.Shutdown", "cron.GracefulStop", "db.GracefulStop")
+}
+
+func TestPanicInRun(t *testing.T) {
+	rec := apptest.NewRecorder()
+
+	_, err := apptest.StartE(t, newApp(t, rec, apptest.Faults{}, apptest.Faults{}, apptest.Faults{RunPanic: "boom"}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom")
+}
+
+func TestRunErrorStopsApp(t *testing.T) {
+	rec := apptest.NewRecorder()
+	errRun := errors.New("listen failed")
+
+	h, err := apptest.StartE(t, newApp(t, rec, apptest.Faults{}, apptest.Faults{RunErr: errRun}, apptest.Faults{}))
+	if err == nil {
+		<-h.Done()
+	}
+
+	require.ErrorIs(t, h.Err(), errRun)
+	rec.AssertShutdownOrder(t, "http", "cron", "db")
+}
+
+func TestStopFlushesSpans(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "spans.json")
+	a := newApp(t, apptest.NewRecorder(), apptest.Faults{}, apptest.Faults{}, apptest.Faults{})
+	require.NoError(t, a.InitTracing(context.Background(), tracing.Config{Exporter: tracing.ExporterFile, File: file}))
+
+	h := apptest.Start(t, a)
+
+	_, span := otel.Tracer("apptest").Start(context.Background(), "pending")
+	span.End()
+
+	require.NoError(t, h.Stop())
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Name":"pending"`)
+}
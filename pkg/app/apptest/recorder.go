@@ -0,0 +1,108 @@
+// Package apptest provides an in-process harness for App lifecycle tests:
+// fake drivers, transports, workers and service that record their calls,
+// fault injection and helpers to start and stop the application.
+package apptest
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Method names recorded by fakes
+const (
+	MethodInit           = "Init"
+	MethodInitialization = "Initialization"
+	MethodInitService    = "InitService"
+	MethodBeforeRunHook  = "BeforeRunHook"
+	MethodRun            = "Run"
+	MethodGracefulStop   = "GracefulStop"
+	MethodShutdown       = "Shutdown"
+)
+
+// Recorder collects calls of fakes in the order they happened.
+// Events are formatted as "<name>.<method>", e.g. "db.Init".
+type Recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+// NewRecorder creates an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Record appends event for component name
+func (r *Recorder) Record(name, method string) {
+	r.mu.Lock()
+	r.events = append(r.events, name+"."+method)
+	r.mu.Unlock()
+}
+
+// Events returns a copy of all recorded events
+func (r *Recorder) Events() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.events...)
+}
+
+// Calls returns names of components that received method, in call order
+func (r *Recorder) Calls(method string) []string {
+	var names []string
+
+	for _, ev := range r.Events() {
+		if name, ok := strings.CutSuffix(ev, "."+method); ok {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// ShutdownOrder returns names of components in the order GracefulStop was called
+func (r *Recorder) ShutdownOrder() []string {
+	return r.Calls(MethodGracefulStop)
+}
+
+// Called reports whether event "<name>.<method>" was recorded
+func (r *Recorder) Called(name, method string) bool {
+	for _, ev := range r.Events() {
+		if ev == name+"."+method {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AssertOrder asserts that events were recorded in the given relative order.
+// Other events may appear in between.
+func (r *Recorder) AssertOrder(t testing.TB, events ...string) bool {
+	t.Helper()
+
+	recorded := r.Events()
+	pos := 0
+
+	for _, ev := range recorded {
+		if pos < len(events) && ev == events[pos] {
+			pos++
+		}
+	}
+
+	if pos == len(events) {
+		return true
+	}
+
+	return assert.Fail(t, "events are not recorded in expected order",
+		"missing %q\nexpected order: %v\nrecorded: %v", events[pos], events, recorded)
+}
+
+// AssertShutdownOrder asserts the exact order of GracefulStop calls
+func (r *Recorder) AssertShutdownOrder(t testing.TB, names ...string) bool {
+	t.Helper()
+
+	return assert.Equal(t, names, r.ShutdownOrder(), "unexpected shutdown order")
+}
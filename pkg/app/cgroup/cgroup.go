@@ -0,0 +1,143 @@
+// Package cgroup reads resource limits of the current container from the
+// cgroup filesystem. Both cgroup v1 and v2 (unified) hierarchies are supported.
+// Only the root of the hierarchy is inspected, which is what a container sees
+// when the cgroup namespace is enabled.
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultRoot is the mount point of the cgroup filesystem
+const DefaultRoot = "/sys/fs/cgroup"
+
+const (
+	v2MemoryMax   = "memory.max"
+	v1MemoryLimit = "memory/memory.limit_in_bytes"
+
+	v2CPUMax = "cpu.max"
+
+	// cgroup v1 cpu controller may be mounted separately or together with cpuacct
+	v1CPUQuota          = "cpu/cpu.cfs_quota_us"
+	v1CPUPeriod         = "cpu/cpu.cfs_period_us"
+	v1CPUAcctQuota      = "cpu,cpuacct/cpu.cfs_quota_us"
+	v1CPUAcctPeriod     = "cpu,cpuacct/cpu.cfs_period_us"
+	cpuMaxFieldsCount   = 2
+	v1UnlimitedCPUQuota = "-1"
+
+	// cgroup v1 reports "no limit" as a page aligned MaxInt64
+	v1UnlimitedThreshold = int64(1) << 62
+)
+
+// Reader reads limits from a cgroup filesystem mounted at Root
+type Reader struct {
+	Root string
+}
+
+// NewReader creates a Reader for the given root, DefaultRoot is used when root is empty
+func NewReader(root string) *Reader {
+	if root == "" {
+		root = DefaultRoot
+	}
+
+	return &Reader{Root: root}
+}
+
+// MemoryLimit returns the memory limit in bytes.
+// ok is false when no limit is set or the cgroup filesystem is not available.
+func (r *Reader) MemoryLimit() (limit int64, ok bool, err error) {
+	raw, found, err := r.readFirst(v2MemoryMax, v1MemoryLimit)
+	if err != nil || !found {
+		return 0, false, err
+	}
+
+	if raw == "max" {
+		return 0, false, nil
+	}
+
+	limit, err = strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, false, errors.Wrapf(err, "parse memory limit %q", raw)
+	}
+
+	if limit <= 0 || limit >= v1UnlimitedThreshold {
+		return 0, false, nil
+	}
+
+	return limit, true, nil
+}
+
+// CPUQuota returns the number of CPUs available to the cgroup, e.g. 1.5 for "150000 100000".
+// ok is false when no quota is set or the cgroup filesystem is not available.
+func (r *Reader) CPUQuota() (cpus float64, ok bool, err error) {
+	raw, found, err := r.readFirst(v2CPUMax)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if found {
+		fields := strings.Fields(raw)
+		if len(fields) != cpuMaxFieldsCount {
+			return 0, false, errors.Errorf("invalid %s format %q", v2CPUMax, raw)
+		}
+
+		if fields[0] == "max" {
+			return 0, false, nil
+		}
+
+		return parseQuota(fields[0], fields[1])
+	}
+
+	quota, found, err := r.readFirst(v1CPUQuota, v1CPUAcctQuota)
+	if err != nil || !found || quota == v1UnlimitedCPUQuota {
+		return 0, false, err
+	}
+
+	period, found, err := r.readFirst(v1CPUPeriod, v1CPUAcctPeriod)
+	if err != nil || !found {
+		return 0, false, err
+	}
+
+	return parseQuota(quota, period)
+}
+
+func parseQuota(rawQuota, rawPeriod string) (float64, bool, error) {
+	quota, err := strconv.ParseInt(rawQuota, 10, 64)
+	if err != nil {
+		return 0, false, errors.Wrapf(err, "parse cpu quota %q", rawQuota)
+	}
+
+	period, err := strconv.ParseInt(rawPeriod, 10, 64)
+	if err != nil {
+		return 0, false, errors.Wrapf(err, "parse cpu period %q", rawPeriod)
+	}
+
+	if quota <= 0 || period <= 0 {
+		return 0, false, nil
+	}
+
+	return float64(quota) / float64(period), true, nil
+}
+
+// readFirst returns trimmed content of the first existing file from names
+func (r *Reader) readFirst(names ...string) (string, bool, error) {
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(r.Root, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return "", false, errors.Wrapf(err, "read %s", name)
+		}
+
+		return strings.TrimSpace(string(data)), true, nil
+	}
+
+	return "", false, nil
+}
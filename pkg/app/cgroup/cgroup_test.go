@@ -0,0 +1,41 @@
+package cgroup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		root     string
+		memory   int64
+		memoryOK bool
+		cpus     float64
+		cpusOK   bool
+	}{
+		{name: "v1", root: "testdata/v1", memory: 512 << 20, memoryOK: true, cpus: 1.5, cpusOK: true},
+		{name: "v1 unlimited", root: "testdata/v1-unlimited"},
+		{name: "v2", root: "testdata/v2", memory: 1 << 30, memoryOK: true, cpus: 2.5, cpusOK: true},
+		{name: "v2 unlimited", root: "testdata/v2-unlimited"},
+		{name: "missing", root: "testdata/missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReader(tt.root)
+
+			memory, ok, err := r.MemoryLimit()
+			require.NoError(t, err)
+			assert.Equal(t, tt.memoryOK, ok)
+			assert.Equal(t, tt.memory, memory)
+
+			cpus, ok, err := r.CPUQuota()
+			require.NoError(t, err)
+			assert.Equal(t, tt.cpusOK, ok)
+			assert.InDelta(t, tt.cpus, cpus, 0.0001)
+		})
+	}
+}
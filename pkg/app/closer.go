@@ -20,7 +20,7 @@ const (
 	loggerObjectName        = "object"
 )
 
-func gracefullyShutdown(shutdownCtx context.Context, closer IGracefulShuhtdown, name string) {
+func gracefullyShutdown(shutdownCtx context.Context, closer IGracefulShuhtdown, name string, timeout time.Duration) {
 	stopped, err := closer.GracefulStop(shutdownCtx)
 	if err != nil {
 		err = closer.Shutdown(shutdownCtx)
@@ -32,7 +32,7 @@ func gracefullyShutdown(shutdownCtx context.Context, closer IGracefulShuhtdown,
 	}
 
 	// hard limit
-	t := time.NewTimer(gracefulShutdownTimeout)
+	t := time.NewTimer(timeout)
 	select {
 	case <-t.C:
 		err = closer.Shutdown(shutdownCtx)
@@ -47,7 +47,24 @@ func gracefullyShutdown(shutdownCtx context.Context, closer IGracefulShuhtdown,
 func (a *App) InitGracefulStop(ctx context.Context) context.Context {
 	// graceful shutdown
 	// Note: SIGKILL cannot be caught in Unix, so we only listen for SIGINT and SIGTERM
-	ctx, a.ctxStop = signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	ctx, stopSignals := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+
+	ctx = a.InitStop(ctx)
+
+	cancel := a.ctxStop
+	a.ctxStop = func() {
+		cancel()
+		stopSignals()
+	}
+
+	return ctx
+}
+
+// InitStop prepares stop context and error group without binding OS signals.
+// Use it when signals are handled elsewhere, e.g. in tests. The application
+// is stopped by Stop or by cancellation of ctx.
+func (a *App) InitStop(ctx context.Context) context.Context {
+	ctx, a.ctxStop = context.WithCancel(ctx)
 
 	// init error group
 	a.errGr, ctx = errgroup.WithContext(ctx)
@@ -58,29 +75,42 @@ func (a *App) InitGracefulStop(ctx context.Context) context.Context {
 func (a *App) gracefulStop(ctx context.Context) error {
 	var err error
 
+	// guard must not restore readiness while we are stopping
+	if a.guard != nil {
+		a.guard.Stop()
+	}
+
 	a.ready.Store(false) // помечаем, что приложение не готово принимать запросы
 
-	shutdownCtx, shutdownCancel := context.WithTimeout(ctx, gracefulShutdownTimeout)
+	shutdownCtx, shutdownCancel := context.WithTimeout(ctx, a.shutdownTimeout)
 	defer shutdownCancel()
 
 	if len(a.transports) > 0 {
 		for _, transport := range a.transports {
-			gracefullyShutdown(shutdownCtx, transport, "Transport "+transport.Name())
+			gracefullyShutdown(shutdownCtx, transport, "Transport "+transport.Name(), a.shutdownTimeout)
 		}
 	}
 
 	if len(a.workers) > 0 {
 		for _, worker := range a.workers {
-			gracefullyShutdown(shutdownCtx, worker, "Worker "+worker.Name())
+			gracefullyShutdown(shutdownCtx, worker, "Worker "+worker.Name(), a.shutdownTimeout)
 		}
 	}
 
 	if len(a.drivers) > 0 {
 		for _, driver := range a.drivers {
-			gracefullyShutdown(shutdownCtx, driver, "Driver "+driver.Name())
+			gracefullyShutdown(shutdownCtx, driver, "Driver "+driver.Name(), a.shutdownTimeout)
 		}
 	}
 
+	// spans of drivers are flushed too, ctx is already cancelled here
+	if a.tracer != nil {
+		tracerCtx, tracerCancel := context.WithTimeout(context.WithoutCancel(ctx), a.shutdownTimeout)
+		_ = a.tracer.Shutdown(tracerCtx) // Library code doesn't log errors
+
+		tracerCancel()
+	}
+
 	err = a.errGr.Wait()
 	if errors.Is(err, context.Canceled) {
 		err = nil
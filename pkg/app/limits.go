@@ -0,0 +1,126 @@
+package app
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/go-faster/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/cgroup"
+)
+
+const (
+	defaultMemoryHeadroom = 0.1
+
+	envGoMaxProcs = "GOMAXPROCS"
+	envGoMemLimit = "GOMEMLIMIT"
+)
+
+// RuntimeLimitsConfig configures GOMAXPROCS and GOMEMLIMIT tuning from cgroup limits
+type RuntimeLimitsConfig struct {
+	// CgroupRoot overrides cgroup.DefaultRoot
+	CgroupRoot string
+
+	// MemoryHeadroom is a fraction of the cgroup memory limit left for non-Go memory
+	// (stacks of cgo threads, page cache, etc.). GOMEMLIMIT = limit * (1 - MemoryHeadroom).
+	// Default is 0.1.
+	MemoryHeadroom float64
+
+	// OverrideEnv allows to override GOMAXPROCS and GOMEMLIMIT set via environment
+	OverrideEnv bool
+}
+
+// RuntimeLimits are values chosen at startup, zero means "left untouched"
+type RuntimeLimits struct {
+	GoMaxProcs int
+	GoMemLimit int64
+}
+
+// WithRuntimeLimits sets GOMAXPROCS and GOMEMLIMIT from cgroup CPU quota and memory limit in New
+func WithRuntimeLimits(cfg RuntimeLimitsConfig) Option {
+	return func(a *App) {
+		a.limitsCfg = &cfg
+	}
+}
+
+// computeRuntimeLimits calculates GOMAXPROCS and GOMEMLIMIT from cgroup limits
+func computeRuntimeLimits(cfg RuntimeLimitsConfig) (RuntimeLimits, error) {
+	var limits RuntimeLimits
+
+	headroom := cfg.MemoryHeadroom
+	if headroom <= 0 || headroom >= 1 {
+		headroom = defaultMemoryHeadroom
+	}
+
+	reader := cgroup.NewReader(cfg.CgroupRoot)
+
+	if _, set := os.LookupEnv(envGoMaxProcs); !set || cfg.OverrideEnv {
+		cpus, ok, err := reader.CPUQuota()
+		if err != nil {
+			return limits, errors.Wrap(err, "can't read cgroup cpu quota")
+		}
+
+		if ok {
+			limits.GoMaxProcs = max(1, int(math.Floor(cpus)))
+		}
+	}
+
+	if _, set := os.LookupEnv(envGoMemLimit); !set || cfg.OverrideEnv {
+		memory, ok, err := reader.MemoryLimit()
+		if err != nil {
+			return limits, errors.Wrap(err, "can't read cgroup memory limit")
+		}
+
+		if ok {
+			limits.GoMemLimit = int64(float64(memory) * (1 - headroom))
+		}
+	}
+
+	return limits, nil
+}
+
+// applyRuntimeLimits sets runtime limits and reports them in AppInfo
+func (a *App) applyRuntimeLimits() error {
+	limits, err := computeRuntimeLimits(*a.limitsCfg)
+	if err != nil {
+		return err
+	}
+
+	if limits.GoMaxProcs > 0 {
+		runtime.GOMAXPROCS(limits.GoMaxProcs)
+	}
+
+	if limits.GoMemLimit > 0 {
+		debug.SetMemoryLimit(limits.GoMemLimit)
+	}
+
+	if a.info != nil {
+		a.info.GoMaxProcs = runtime.GOMAXPROCS(0)
+		a.info.GoMemLimit = debug.SetMemoryLimit(-1)
+	}
+
+	return nil
+}
+
+// runtimeLimitsCollectors returns gauges of effective GOMAXPROCS and GOMEMLIMIT
+func runtimeLimitsCollectors(name string) []prometheus.Collector {
+	return []prometheus.Collector{
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: name + "_gomaxprocs",
+				Help: "Effective GOMAXPROCS of the " + name + " app.",
+			},
+			func() float64 { return float64(runtime.GOMAXPROCS(0)) },
+		),
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: name + "_gomemlimit_bytes",
+				Help: "Effective GOMEMLIMIT of the " + name + " app.",
+			},
+			func() float64 { return float64(debug.SetMemoryLimit(-1)) },
+		),
+	}
+}
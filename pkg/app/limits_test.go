@@ -0,0 +1,32 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeRuntimeLimits(t *testing.T) {
+	limits, err := computeRuntimeLimits(RuntimeLimitsConfig{
+		CgroupRoot:     "cgroup/testdata/v2",
+		MemoryHeadroom: 0.25,
+		OverrideEnv:    true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, limits.GoMaxProcs)
+	assert.Equal(t, int64(768<<20), limits.GoMemLimit)
+
+	limits, err = computeRuntimeLimits(RuntimeLimitsConfig{CgroupRoot: "cgroup/testdata/v2-unlimited", OverrideEnv: true})
+	require.NoError(t, err)
+	assert.Zero(t, limits)
+}
+
+func TestComputeRuntimeLimits_RespectsEnv(t *testing.T) {
+	t.Setenv(envGoMaxProcs, "8")
+	t.Setenv(envGoMemLimit, "1GiB")
+
+	limits, err := computeRuntimeLimits(RuntimeLimitsConfig{CgroupRoot: "cgroup/testdata/v1"})
+	require.NoError(t, err)
+	assert.Zero(t, limits)
+}
@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"strconv"
+
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
@@ -33,6 +35,7 @@ func InfoCollector(desc *prometheus.Desc) prometheus.Collector {
 	}
 }
 
+// BuildInfoCollector exports AppInfo as labels of the constant <name>_build_info metric
 func BuildInfoCollector(name string, info *ds.AppInfo) prometheus.Collector {
 	return InfoCollector(
 		prometheus.NewDesc(
@@ -44,6 +47,12 @@ func BuildInfoCollector(name string, info *ds.AppInfo) prometheus.Collector {
 				"version":     info.Version,
 				"buildCommit": info.BuildCommit,
 				"buildtime":   info.BuildTime,
+				"goversion":   info.GoVersion,
+				"goos":        info.GOOS,
+				"goarch":      info.GOARCH,
+				"dirty":       strconv.FormatBool(info.VCSModified),
+				"vcstime":     info.VCSTime,
+				"hostname":    info.Hostname,
 			},
 		),
 	)
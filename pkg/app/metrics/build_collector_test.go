@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+)
+
+func TestBuildInfoCollector(t *testing.T) {
+	info := ds.NewAppInfo("app").WithVersion("v1.2.3").WithBuildCommit("abc123")
+	info.VCSModified = true
+	info.VCSTime = "2025-01-29T10:00:00Z"
+	info.GoVersion = "go1.24.4"
+	info.GOOS = "linux"
+	info.GOARCH = "amd64"
+	info.Hostname = "host-1"
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(BuildInfoCollector("app", info)))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	assert.Equal(t, "app_build_info", families[0].GetName())
+	require.Len(t, families[0].GetMetric(), 1)
+
+	labels := map[string]string{}
+	for _, l := range families[0].GetMetric()[0].GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+
+	assert.Equal(t, map[string]string{
+		"appname":     "app",
+		"version":     "v1.2.3",
+		"buildCommit": "abc123",
+		"buildtime":   "",
+		"goversion":   "go1.24.4",
+		"goos":        "linux",
+		"goarch":      "amd64",
+		"dirty":       "true",
+		"vcstime":     "2025-01-29T10:00:00Z",
+		"hostname":    "host-1",
+	}, labels)
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Response headers set by Middleware
+const (
+	HeaderLimit      = "X-RateLimit-Limit"
+	HeaderRemaining  = "X-RateLimit-Remaining"
+	HeaderRetryAfter = "Retry-After"
+)
+
+// HTTPConfig of Middleware
+type HTTPConfig struct {
+	// Route names the route in Rules, RoutePattern by default. When the
+	// middleware wraps the whole mux use MuxRoute.
+	Route func(r *http.Request) string
+
+	// ClientIP keys anonymous callers, by default the host of RemoteAddr.
+	// Behind a proxy use a function trusting its forwarding header.
+	ClientIP func(r *http.Request) string
+
+	// OnError handles failures of the store, by default the request is allowed
+	OnError func(w http.ResponseWriter, r *http.Request, err error) bool
+
+	// OnLimited writes the response of rejected requests, 429 Too Many Requests by default.
+	// Retry-After is already set.
+	OnLimited http.Handler
+}
+
+// Middleware limits requests to the handler. It must run after authentication
+// so the actor is in the request context.
+func (l *Limiter) Middleware(cfg HTTPConfig) func(http.Handler) http.Handler {
+	if cfg.Route == nil {
+		cfg.Route = RoutePattern
+	}
+
+	if cfg.ClientIP == nil {
+		cfg.ClientIP = RemoteIP
+	}
+
+	if cfg.OnLimited == nil {
+		cfg.OnLimited = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		})
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d, err := l.Allow(r.Context(), cfg.Route(r), cfg.ClientIP(r))
+			if err != nil {
+				if cfg.OnError == nil || cfg.OnError(w, r, err) {
+					next.ServeHTTP(w, r)
+				}
+
+				return
+			}
+
+			if !d.Limit.Unlimited() {
+				w.Header().Set(HeaderLimit, strconv.Itoa(d.Limit.Requests))
+				w.Header().Set(HeaderRemaining, strconv.Itoa(d.Remaining))
+			}
+
+			if !d.Allowed {
+				w.Header().Set(HeaderRetryAfter, RetryAfterSeconds(d.RetryAfter))
+				cfg.OnLimited.ServeHTTP(w, r)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RoutePattern returns the matched http.ServeMux pattern ("POST /api/orders")
+// or the URL path if the request is not routed yet
+func RoutePattern(r *http.Request) string {
+	if r.Pattern != "" {
+		return r.Pattern
+	}
+
+	return r.URL.Path
+}
+
+// MuxRoute returns the pattern of mux matching the request, the URL path if none matches
+func MuxRoute(mux *http.ServeMux) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			return pattern
+		}
+
+		return r.URL.Path
+	}
+}
+
+// RemoteIP returns the host of RemoteAddr
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// RetryAfterSeconds formats d for the Retry-After header, rounded up to whole seconds
+func RetryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(max(1, int(math.Ceil(d.Seconds()))))
+}
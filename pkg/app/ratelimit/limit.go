@@ -0,0 +1,197 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/go-faster/errors"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+)
+
+// Algorithm of a limit
+type Algorithm string
+
+const (
+	// TokenBucket allows bursts up to Burst requests, refilled at Requests per Window
+	TokenBucket Algorithm = "token_bucket"
+	// SlidingWindow allows Requests per any Window, approximated by two fixed windows
+	SlidingWindow Algorithm = "sliding_window"
+)
+
+var (
+	errBadWindow    = errors.New("window must be positive")
+	errBadAlgorithm = errors.New("unknown algorithm")
+	errBadDuration  = errors.New("invalid duration")
+)
+
+// Duration is time.Duration parsed from strings like "1m" or numbers of seconds in JSON
+type Duration time.Duration
+
+// UnmarshalJSON parses "1m30s" or 90
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.Wrap(errBadDuration, string(data))
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.Wrap(errBadDuration, s)
+	}
+
+	*d = Duration(parsed)
+
+	return nil
+}
+
+// MarshalJSON formats the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Limit allows Requests per Window. Zero Requests means no limit.
+type Limit struct {
+	// Algorithm is TokenBucket by default
+	Algorithm Algorithm `json:"algorithm"`
+	Requests  int       `json:"requests"`
+	Window    Duration  `json:"window"`
+	// Burst is the token bucket capacity, Requests by default
+	Burst int `json:"burst"`
+}
+
+// Unlimited reports whether the limit allows everything
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0
+}
+
+func (l Limit) algorithm() Algorithm {
+	if l.Algorithm == "" {
+		return TokenBucket
+	}
+
+	return l.Algorithm
+}
+
+func (l Limit) burst() int {
+	if l.Burst <= 0 {
+		return l.Requests
+	}
+
+	return l.Burst
+}
+
+func (l Limit) validate() error {
+	if l.Unlimited() {
+		return nil
+	}
+
+	if l.Window <= 0 {
+		return errBadWindow
+	}
+
+	switch l.algorithm() {
+	case TokenBucket, SlidingWindow:
+		return nil
+	default:
+		return errors.Wrapf(errBadAlgorithm, "%q", l.Algorithm)
+	}
+}
+
+// Limits are the default limit and overrides by actor kind
+type Limits struct {
+	Default *Limit                `json:"default"`
+	Kinds   map[actor.Kind]*Limit `json:"kinds"`
+}
+
+func (l Limits) lookup(kind actor.Kind) (*Limit, bool) {
+	if limit, ok := l.Kinds[kind]; ok && limit != nil {
+		return limit, true
+	}
+
+	return l.Default, l.Default != nil
+}
+
+func (l Limits) validate() error {
+	if l.Default != nil {
+		if err := l.Default.validate(); err != nil {
+			return errors.Wrap(err, "default")
+		}
+	}
+
+	for kind, limit := range l.Kinds {
+		if limit == nil {
+			continue
+		}
+
+		if err := limit.validate(); err != nil {
+			return errors.Wrapf(err, "kind %q", kind)
+		}
+	}
+
+	return nil
+}
+
+// Rules of the limiter. In onlineconf (JSON):
+//
+//	{
+//	  "default": {"requests": 100, "window": "1m"},
+//	  "kinds": {"anonymous": {"requests": 20, "window": "1m"}, "system": {"requests": 0}},
+//	  "routes": {
+//	    "POST /api/orders": {"default": {"algorithm": "sliding_window", "requests": 10, "window": "1m"}},
+//	    "telegram:start": {"default": {"requests": 1, "window": "5s"}}
+//	  }
+//	}
+//
+// A route limit overrides the global one, a kind limit overrides the default
+// of the same level. Requests are counted separately for every route with its
+// own limits, global limits are shared by all other routes.
+type Rules struct {
+	Limits
+	Routes map[string]Limits `json:"routes"`
+}
+
+func (r *Rules) validate() error {
+	if err := r.Limits.validate(); err != nil {
+		return err
+	}
+
+	for route, limits := range r.Routes {
+		if err := limits.validate(); err != nil {
+			return errors.Wrapf(err, "route %q", route)
+		}
+	}
+
+	return nil
+}
+
+// globalScope is the scope of global limits in store keys
+const globalScope = "*"
+
+// lookup returns the limit of route and kind with its scope: the route or
+// globalScope if the global limit applies
+func (r *Rules) lookup(route string, kind actor.Kind) (Limit, string, bool) {
+	if limits, ok := r.Routes[route]; ok {
+		if limit, ok := limits.lookup(kind); ok {
+			return *limit, route, true
+		}
+	}
+
+	if limit, ok := r.Limits.lookup(kind); ok {
+		return *limit, globalScope, true
+	}
+
+	return Limit{}, "", false
+}
+
+// storeKey joins scope and caller key, the separator is escaped in the scope
+func storeKey(scope, key string) string {
+	return strings.ReplaceAll(scope, "|", "_") + "|" + key
+}
@@ -0,0 +1,216 @@
+// Package ratelimit throttles callers by the actor of reqctx or, for anonymous
+// callers, by client IP.
+//
+// Limits are set per route and actor kind (see Rules) and may be reloaded from
+// onlineconf. Counting is done by a Store: MemoryStore keeps state in the
+// process, shared stores let replicas enforce common limits. Middleware and
+// Telegram adapt the limiter to HTTP handlers and bot commands.
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Educentr/go-onlineconf/pkg/onlineconf"
+	"github.com/go-faster/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
+)
+
+// routeDefault labels rejections by global limits
+const routeDefault = "default"
+
+// ErrLimited is matched by errors of rejected requests
+var ErrLimited = errors.New("rate limit exceeded")
+
+// LimitedError is a rejected request
+type LimitedError struct {
+	Route      string
+	RetryAfter time.Duration
+}
+
+func (e *LimitedError) Error() string {
+	return ErrLimited.Error() + " on " + strconv.Quote(e.Route) + ", retry after " + e.RetryAfter.String()
+}
+
+// Is matches ErrLimited
+func (e *LimitedError) Is(target error) bool {
+	return target == ErrLimited
+}
+
+// Decision on a request
+type Decision struct {
+	Result
+	// Limit applied to the request, zero if the request is not limited
+	Limit Limit
+}
+
+// Config of the limiter
+type Config struct {
+	Rules Rules
+
+	// Store is a MemoryStore by default
+	Store Store
+
+	// Namespace of exported metrics
+	Namespace string
+
+	// Now is used in tests
+	Now func() time.Time
+}
+
+// Limiter decides whether requests are allowed. Rules can be replaced at runtime.
+type Limiter struct {
+	cfg        Config
+	rules      atomic.Pointer[Rules]
+	rejections *prometheus.CounterVec
+}
+
+// New creates the limiter
+func New(cfg Config) (*Limiter, error) {
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryStore(0)
+	}
+
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	l := &Limiter{
+		cfg: cfg,
+		rejections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: cfg.Namespace,
+				Subsystem: "ratelimit",
+				Name:      "rejections_total",
+				Help:      "Total number of requests rejected by rate limits by route and actor kind",
+			},
+			[]string{"route", "kind"},
+		),
+	}
+
+	if err := l.SetRules(cfg.Rules); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Collectors returns metrics of the limiter to register
+func (l *Limiter) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{l.rejections}
+}
+
+// SetRules validates and atomically replaces the rules, on error the previous ones are kept
+func (l *Limiter) SetRules(rules Rules) error {
+	if err := rules.validate(); err != nil {
+		return err
+	}
+
+	l.rules.Store(&rules)
+
+	return nil
+}
+
+// Subscribe loads rules from onlineconf path (JSON) and reloads them on updates
+func (l *Limiter) Subscribe(ctx context.Context, path string) error {
+	reload := func() error {
+		var rules Rules
+
+		found, err := onlineconf.GetStruct(ctx, path, &rules)
+		if err != nil {
+			return errors.Wrapf(err, "can't read rate limits from %s", path)
+		}
+
+		if !found {
+			return errors.Errorf("rate limits %s not found", path)
+		}
+
+		return l.SetRules(rules)
+	}
+
+	if err := reload(); err != nil {
+		return err
+	}
+
+	if err := onlineconf.RegisterSubscription(ctx, onlineconf.DefaultModule, []string{path}, reload); err != nil {
+		return errors.Wrap(err, "can't subscribe to rate limits update")
+	}
+
+	return nil
+}
+
+// Allow counts the request to route by the actor of ctx, fallback is the key
+// of anonymous callers, e.g. client IP
+func (l *Limiter) Allow(ctx context.Context, route, fallback string) (Decision, error) {
+	kind, key := callerKey(ctx, fallback)
+
+	limit, scope, ok := l.rules.Load().lookup(route, kind)
+	if !ok || limit.Unlimited() {
+		return Decision{Result: Result{Allowed: true}}, nil
+	}
+
+	res, err := l.cfg.Store.Take(ctx, storeKey(scope, key), limit, l.cfg.Now())
+	if err != nil {
+		return Decision{}, errors.Wrap(err, "rate limit store")
+	}
+
+	if !res.Allowed {
+		label := route
+		if scope == globalScope {
+			label = routeDefault
+		}
+
+		l.rejections.WithLabelValues(label, string(kind)).Inc()
+	}
+
+	return Decision{Result: res, Limit: limit}, nil
+}
+
+// Check is Allow returning *LimitedError for rejected requests
+func (l *Limiter) Check(ctx context.Context, route, fallback string) error {
+	d, err := l.Allow(ctx, route, fallback)
+	if err != nil {
+		return err
+	}
+
+	if !d.Allowed {
+		return &LimitedError{Route: route, RetryAfter: d.RetryAfter}
+	}
+
+	return nil
+}
+
+// callerKey returns kind of the caller and its key: kind and ID of the actor,
+// kind and fallback for anonymous callers and actors without IDs
+func callerKey(ctx context.Context, fallback string) (actor.Kind, string) {
+	act, err := reqctx.GetActor(ctx)
+	if err != nil {
+		return actor.KindAnonymous, string(actor.KindAnonymous) + ":@" + fallback
+	}
+
+	kind := actor.KindOf(act)
+
+	if id := actorID(act); id != "" && kind != actor.KindAnonymous {
+		return kind, string(kind) + ":" + id
+	}
+
+	return kind, string(kind) + ":@" + fallback
+}
+
+func actorID(act ds.Actor) string {
+	if act.GetID() != 0 {
+		return strconv.FormatInt(act.GetID(), 10)
+	}
+
+	if p, ok := act.(actor.StringIDProvider); ok && p.GetStringID() != "" {
+		return "s:" + p.GetStringID()
+	}
+
+	return ""
+}
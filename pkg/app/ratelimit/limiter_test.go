@@ -0,0 +1,174 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Educentr/go-onlineconf/pkg/onlineconf"
+	"github.com/colinmarc/cdb"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
+)
+
+func limitOf(requests int) *Limit {
+	return &Limit{Requests: requests, Window: Duration(time.Minute)}
+}
+
+func newLimiter(t *testing.T) *Limiter {
+	t.Helper()
+
+	now := time.Unix(1_700_000_000, 0)
+
+	l, err := New(Config{
+		Rules: Rules{
+			Limits: Limits{
+				Default: limitOf(3),
+				Kinds:   map[actor.Kind]*Limit{actor.KindAnonymous: limitOf(1), actor.KindSystem: limitOf(0)},
+			},
+			Routes: map[string]Limits{
+				"POST /orders": {Default: limitOf(2)},
+			},
+		},
+		Now: func() time.Time { return now },
+	})
+	require.NoError(t, err)
+
+	return l
+}
+
+func allowed(t *testing.T, l *Limiter, ctx context.Context, route, ip string) bool {
+	t.Helper()
+
+	d, err := l.Allow(ctx, route, ip)
+	require.NoError(t, err)
+
+	return d.Allowed
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	l := newLimiter(t)
+
+	user, err := reqctx.SetActor(context.Background(), &actor.Actor{ID: 1})
+	require.NoError(t, err)
+
+	// global limit is shared by routes without own limits
+	assert.True(t, allowed(t, l, user, "GET /a", "10.0.0.1"))
+	assert.True(t, allowed(t, l, user, "GET /b", "10.0.0.2"))
+	assert.True(t, allowed(t, l, user, "GET /a", "10.0.0.1"))
+	assert.False(t, allowed(t, l, user, "GET /b", "10.0.0.1"))
+
+	// route limit is counted separately
+	assert.True(t, allowed(t, l, user, "POST /orders", "10.0.0.1"))
+	assert.True(t, allowed(t, l, user, "POST /orders", "10.0.0.1"))
+	assert.False(t, allowed(t, l, user, "POST /orders", "10.0.0.1"))
+
+	// anonymous callers are keyed by IP with the kind limit
+	anon := context.Background()
+	assert.True(t, allowed(t, l, anon, "GET /a", "10.0.0.1"))
+	assert.False(t, allowed(t, l, anon, "GET /a", "10.0.0.1"))
+	assert.True(t, allowed(t, l, anon, "GET /a", "10.0.0.2"))
+
+	// zero limit disables limiting
+	system, err := reqctx.SetActor(context.Background(), actor.NewSystem("cron"))
+	require.NoError(t, err)
+
+	for range 5 {
+		assert.True(t, allowed(t, l, system, "GET /a", ""))
+	}
+
+	assert.InDelta(t, 1, testutil.ToFloat64(l.rejections.WithLabelValues(routeDefault, string(actor.KindUser))), 0)
+	assert.InDelta(t, 1, testutil.ToFloat64(l.rejections.WithLabelValues("POST /orders", string(actor.KindUser))), 0)
+	assert.InDelta(t, 1, testutil.ToFloat64(l.rejections.WithLabelValues(routeDefault, string(actor.KindAnonymous))), 0)
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	l := newLimiter(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /orders", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	handler := l.Middleware(HTTPConfig{Route: MuxRoute(mux)})(mux)
+
+	do := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/orders", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	for remaining := 1; remaining >= 0; remaining-- {
+		w := do()
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "2", w.Header().Get(HeaderLimit))
+		assert.Equal(t, strconv.Itoa(remaining), w.Header().Get(HeaderRemaining))
+	}
+
+	w := do()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get(HeaderRetryAfter))
+}
+
+func TestLimiter_Telegram(t *testing.T) {
+	l, err := New(Config{Rules: Rules{Routes: map[string]Limits{
+		TelegramRoutePrefix + "start": {Default: limitOf(1)},
+	}}})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	require.NoError(t, l.Telegram(ctx, "start", ds.TelegramUpdate{UserID: 5}))
+	require.NoError(t, l.Telegram(ctx, "help", ds.TelegramUpdate{UserID: 5}))
+	require.NoError(t, l.Telegram(ctx, "start", ds.TelegramUpdate{UserID: 6}))
+
+	err = l.Telegram(ctx, "start", ds.TelegramUpdate{UserID: 5})
+	require.ErrorIs(t, err, ErrLimited)
+
+	var limited *LimitedError
+	require.ErrorAs(t, err, &limited)
+	assert.Positive(t, limited.RetryAfter)
+}
+
+func TestLimiter_Subscribe(t *testing.T) {
+	dir := t.TempDir()
+
+	writer, err := cdb.Create(filepath.Join(dir, onlineconf.DefaultModule+".cdb"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Put([]byte("/test/ratelimit"), []byte(`j{"default":{"requests":1,"window":"1m"},"routes":{"GET /a":{"kinds":{"user":{"algorithm":"sliding_window","requests":2,"window":60}}}}}`)))
+	require.NoError(t, writer.Put([]byte("/test/ratelimit-bad"), []byte(`j{"default":{"requests":1,"window":"1m","algorithm":"leaky"}}`)))
+	require.NoError(t, writer.Close())
+
+	ctx, err := onlineconf.Initialize(context.Background(), onlineconf.WithConfigDir(dir))
+	require.NoError(t, err)
+
+	l, err := New(Config{})
+	require.NoError(t, err)
+
+	require.NoError(t, l.Subscribe(ctx, "/test/ratelimit"))
+
+	rules := l.rules.Load()
+	limit, scope, ok := rules.lookup("GET /a", actor.KindUser)
+	require.True(t, ok)
+	assert.Equal(t, "GET /a", scope)
+	assert.Equal(t, Limit{Algorithm: SlidingWindow, Requests: 2, Window: Duration(time.Minute)}, limit)
+
+	limit, scope, ok = rules.lookup("GET /a", actor.KindService)
+	require.True(t, ok)
+	assert.Equal(t, globalScope, scope)
+	assert.Equal(t, 1, limit.Requests)
+
+	require.ErrorIs(t, l.Subscribe(ctx, "/test/ratelimit-bad"), errBadAlgorithm)
+}
@@ -0,0 +1,191 @@
+package ratelimit
+
+import (
+	"context"
+	"hash/maphash"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultShards = 64
+	sweepInterval = time.Minute
+)
+
+// Result of taking a request from the limit
+type Result struct {
+	Allowed bool
+	// Remaining requests available right now
+	Remaining int
+	// RetryAfter is the time until the next request is allowed, zero if allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps limiter state. Implementations backed by shared storage (e.g.
+// Redis) let replicas enforce common limits.
+type Store interface {
+	// Take counts a request by key against limit at now
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+type entry struct {
+	// token bucket
+	tokens float64
+	last   time.Time
+
+	// sliding window
+	start time.Time
+	prev  int
+	cur   int
+
+	expires time.Time
+}
+
+type shard struct {
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastSweep time.Time
+}
+
+// MemoryStore is an in-process Store sharded by key to reduce lock contention.
+// Idle keys are swept lazily.
+type MemoryStore struct {
+	seed   maphash.Seed
+	shards []shard
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates the store with n shards, 64 if n <= 0
+func NewMemoryStore(n int) *MemoryStore {
+	if n <= 0 {
+		n = defaultShards
+	}
+
+	s := &MemoryStore{seed: maphash.MakeSeed(), shards: make([]shard, n)}
+
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]*entry)
+	}
+
+	return s
+}
+
+// Take implements Store
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	sh := &s.shards[maphash.String(s.seed, key)%uint64(len(s.shards))]
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	sh.sweep(now)
+
+	e, ok := sh.entries[key]
+	if !ok {
+		e = &entry{tokens: float64(limit.burst()), last: now}
+		sh.entries[key] = e
+	}
+
+	var res Result
+
+	if limit.algorithm() == SlidingWindow {
+		res = e.slidingWindow(limit, now)
+		e.expires = e.start.Add(2 * time.Duration(limit.Window))
+	} else {
+		res = e.tokenBucket(limit, now)
+		e.expires = now.Add(time.Duration(limit.Window))
+	}
+
+	return res, nil
+}
+
+// Len returns the number of tracked keys
+func (s *MemoryStore) Len() int {
+	n := 0
+
+	for i := range s.shards {
+		s.shards[i].mu.Lock()
+		n += len(s.shards[i].entries)
+		s.shards[i].mu.Unlock()
+	}
+
+	return n
+}
+
+func (sh *shard) sweep(now time.Time) {
+	if now.Sub(sh.lastSweep) < sweepInterval {
+		return
+	}
+
+	sh.lastSweep = now
+
+	for key, e := range sh.entries {
+		if !now.Before(e.expires) {
+			delete(sh.entries, key)
+		}
+	}
+}
+
+func (e *entry) tokenBucket(limit Limit, now time.Time) Result {
+	capacity := float64(limit.burst())
+	rate := float64(limit.Requests) / time.Duration(limit.Window).Seconds()
+
+	if elapsed := now.Sub(e.last).Seconds(); elapsed > 0 {
+		e.tokens = math.Min(capacity, e.tokens+elapsed*rate)
+		e.last = now
+	}
+
+	// the capacity may be lowered by a config update
+	e.tokens = math.Min(capacity, e.tokens)
+
+	if e.tokens < 1 {
+		return Result{RetryAfter: time.Duration((1 - e.tokens) / rate * float64(time.Second))}
+	}
+
+	e.tokens--
+
+	return Result{Allowed: true, Remaining: int(e.tokens)}
+}
+
+func (e *entry) slidingWindow(limit Limit, now time.Time) Result {
+	window := time.Duration(limit.Window)
+	start := now.Truncate(window)
+
+	if !start.Equal(e.start) {
+		if start.Sub(e.start) == window {
+			e.prev = e.cur
+		} else {
+			e.prev = 0
+		}
+
+		e.cur = 0
+		e.start = start
+	}
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(window)
+	estimate := float64(e.prev)*weight + float64(e.cur)
+
+	if estimate+1 > float64(limit.Requests) {
+		return Result{RetryAfter: e.retryAfter(limit.Requests, window, elapsed)}
+	}
+
+	e.cur++
+
+	return Result{Allowed: true, Remaining: limit.Requests - int(math.Ceil(estimate+1))}
+}
+
+// retryAfter is the time until the previous window weight drops enough for
+// one more request, the rest of the window if the current one is full
+func (e *entry) retryAfter(requests int, window, elapsed time.Duration) time.Duration {
+	free := requests - e.cur - 1
+	if free < 0 || e.prev == 0 {
+		return window - elapsed
+	}
+
+	// prev * (1 - t/window) <= free
+	at := time.Duration((1 - float64(free)/float64(e.prev)) * float64(window))
+
+	return max(at-elapsed, time.Millisecond)
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_TokenBucket(t *testing.T) {
+	s := NewMemoryStore(4)
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	limit := Limit{Requests: 2, Window: Duration(time.Second), Burst: 3}
+
+	for i := range 3 {
+		res, err := s.Take(ctx, "k", limit, now)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 2-i, res.Remaining)
+	}
+
+	res, err := s.Take(ctx, "k", limit, now)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+	res, err = s.Take(ctx, "k", limit, now.Add(500*time.Millisecond))
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	// other keys are independent
+	res, err = s.Take(ctx, "other", limit, now)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestMemoryStore_SlidingWindow(t *testing.T) {
+	s := NewMemoryStore(0)
+	ctx := context.Background()
+	start := time.Unix(1_700_000_000, 0).Truncate(time.Minute)
+	limit := Limit{Algorithm: SlidingWindow, Requests: 4, Window: Duration(time.Minute)}
+
+	for range 4 {
+		res, err := s.Take(ctx, "k", limit, start.Add(30*time.Second))
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+	}
+
+	res, err := s.Take(ctx, "k", limit, start.Add(45*time.Second))
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 15*time.Second, res.RetryAfter)
+
+	// half of the previous window is still counted: 4*0.5 = 2 of 4
+	next := start.Add(90 * time.Second)
+
+	for range 2 {
+		res, err = s.Take(ctx, "k", limit, next)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+	}
+
+	res, err = s.Take(ctx, "k", limit, next)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 15*time.Second, res.RetryAfter)
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	s := NewMemoryStore(1)
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	limit := Limit{Requests: 1, Window: Duration(time.Second)}
+
+	_, err := s.Take(ctx, "a", limit, now)
+	require.NoError(t, err)
+	_, err = s.Take(ctx, "b", limit, now.Add(2*sweepInterval))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, s.Len())
+}
@@ -0,0 +1,23 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+)
+
+// TelegramRoutePrefix prefixes commands in Rules routes, e.g. "telegram:start"
+const TelegramRoutePrefix = "telegram:"
+
+// Telegram limits a bot command of the update. Callers without an actor in
+// ctx are keyed by the Telegram user. Rejected commands return *LimitedError,
+// the bot may tell the user when to retry.
+func (l *Limiter) Telegram(ctx context.Context, command string, update ds.TelegramUpdate) error {
+	fallback := "tg:" + strconv.FormatInt(update.UserID, 10)
+	if update.UserID == 0 {
+		fallback = "tg-chat:" + strconv.FormatInt(update.ChatID, 10)
+	}
+
+	return l.Check(ctx, TelegramRoutePrefix+command, fallback)
+}
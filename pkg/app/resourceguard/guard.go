@@ -0,0 +1,347 @@
+// Package resourceguard watches memory and goroutine pressure of the process
+// and takes the application out of rotation before it is killed by OOM.
+//
+// When usage crosses the high watermark the guard sets ServerBucket.AppReady
+// to false so the load balancer stops sending traffic. Readiness is restored
+// only after usage drops below the low watermark (hysteresis).
+package resourceguard
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/cgroup"
+)
+
+// Resource is the name of a watched resource
+type Resource string
+
+const (
+	ResourceMemory     Resource = "memory"
+	ResourceGoroutines Resource = "goroutines"
+)
+
+const (
+	defaultInterval = time.Second
+
+	stateOverloaded = "overloaded"
+	stateRecovered  = "recovered"
+
+	metricMemoryTotal    = "/memory/classes/total:bytes"
+	metricMemoryReleased = "/memory/classes/heap/released:bytes"
+)
+
+// Transition describes a change of the pressure state of a single resource
+type Transition struct {
+	Resource Resource
+	// Overloaded is true when the high watermark was crossed and false when usage dropped below the low watermark
+	Overloaded bool
+	Value      int64
+	Limit      int64
+	// Ready is the application readiness after the transition
+	Ready bool
+	At    time.Time
+}
+
+// Config of the guard. Zero watermarks disable the corresponding check.
+type Config struct {
+	// Interval between samples, one second by default
+	Interval time.Duration
+
+	// MemoryHigh and MemoryLow are fractions (0..1] of the memory limit.
+	// The limit is GOMEMLIMIT or, if it is not set, the cgroup memory limit.
+	MemoryHigh float64
+	MemoryLow  float64
+
+	// GoroutinesHigh and GoroutinesLow are absolute goroutine counts
+	GoroutinesHigh int64
+	GoroutinesLow  int64
+
+	// CgroupRoot overrides cgroup.DefaultRoot
+	CgroupRoot string
+
+	// Namespace of exported metrics
+	Namespace string
+
+	// OnTransition is called on every state change of any resource
+	OnTransition func(Transition)
+}
+
+type resourceState struct {
+	overloaded bool
+	value      int64
+	limit      int64
+}
+
+// Guard samples resource usage and toggles application readiness
+type Guard struct {
+	cfg Config
+
+	memoryUsage    func() int64
+	memoryLimit    func() int64
+	goroutineCount func() int64
+
+	transitions *prometheus.CounterVec
+	usage       *prometheus.GaugeVec
+	overloaded  *prometheus.GaugeVec
+
+	mu       sync.Mutex
+	states   map[Resource]*resourceState
+	tripped  bool
+	ready    *atomic.Bool
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// New creates a guard. Watermarks are normalized: a low watermark above the
+// high one is lowered to the high one.
+func New(cfg Config) *Guard {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+
+	if cfg.MemoryLow <= 0 || cfg.MemoryLow > cfg.MemoryHigh {
+		cfg.MemoryLow = cfg.MemoryHigh
+	}
+
+	if cfg.GoroutinesLow <= 0 || cfg.GoroutinesLow > cfg.GoroutinesHigh {
+		cfg.GoroutinesLow = cfg.GoroutinesHigh
+	}
+
+	cgroupReader := cgroup.NewReader(cfg.CgroupRoot)
+
+	g := &Guard{
+		cfg:            cfg,
+		memoryUsage:    readMemoryUsage,
+		goroutineCount: func() int64 { return int64(runtime.NumGoroutine()) },
+		memoryLimit: func() int64 {
+			if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+				return limit
+			}
+
+			if limit, ok, err := cgroupReader.MemoryLimit(); err == nil && ok {
+				return limit
+			}
+
+			return 0
+		},
+		transitions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: cfg.Namespace,
+				Subsystem: "resource_guard",
+				Name:      "transitions_total",
+				Help:      "Total number of resource pressure state transitions",
+			},
+			[]string{"resource", "state"},
+		),
+		usage: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: cfg.Namespace,
+				Subsystem: "resource_guard",
+				Name:      "usage",
+				Help:      "Last sampled resource usage",
+			},
+			[]string{"resource"},
+		),
+		overloaded: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: cfg.Namespace,
+				Subsystem: "resource_guard",
+				Name:      "overloaded",
+				Help:      "1 if resource is above the high watermark and not yet recovered",
+			},
+			[]string{"resource"},
+		),
+		states: map[Resource]*resourceState{
+			ResourceMemory:     {},
+			ResourceGoroutines: {},
+		},
+	}
+
+	return g
+}
+
+// Collectors returns prometheus collectors of the guard
+func (g *Guard) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{g.transitions, g.usage, g.overloaded}
+}
+
+// Start begins sampling in background. ready is the application readiness flag.
+// Start must be called once; the guard is stopped with Stop.
+func (g *Guard) Start(ready *atomic.Bool) {
+	g.mu.Lock()
+	g.ready = ready
+	g.stop = make(chan struct{})
+	g.done = make(chan struct{})
+	g.mu.Unlock()
+
+	go g.loop()
+}
+
+// Stop stops sampling and waits for the sampling goroutine to exit.
+// After Stop returns the guard never touches readiness again.
+func (g *Guard) Stop() {
+	g.mu.Lock()
+	stop, done := g.stop, g.done
+	g.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+
+	g.stopOnce.Do(func() { close(stop) })
+	<-done
+}
+
+// Overloaded reports whether the guard currently holds the application out of rotation
+func (g *Guard) Overloaded() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.tripped
+}
+
+func (g *Guard) loop() {
+	defer close(g.done)
+
+	ticker := time.NewTicker(g.cfg.Interval)
+	defer ticker.Stop()
+
+	g.Check()
+
+	for {
+		select {
+		case <-g.stop:
+			return
+		case <-ticker.C:
+			g.Check()
+		}
+	}
+}
+
+// Check takes a single sample and applies transitions
+func (g *Guard) Check() {
+	var transitions []Transition
+
+	g.mu.Lock()
+
+	if g.cfg.MemoryHigh > 0 {
+		if limit := g.memoryLimit(); limit > 0 {
+			high := int64(float64(limit) * g.cfg.MemoryHigh)
+			low := int64(float64(limit) * g.cfg.MemoryLow)
+			transitions = g.apply(transitions, ResourceMemory, g.memoryUsage(), limit, high, low)
+		}
+	}
+
+	if g.cfg.GoroutinesHigh > 0 {
+		transitions = g.apply(transitions, ResourceGoroutines, g.goroutineCount(), g.cfg.GoroutinesHigh, g.cfg.GoroutinesHigh, g.cfg.GoroutinesLow)
+	}
+
+	if len(transitions) > 0 {
+		g.updateReadiness()
+
+		for i := range transitions {
+			transitions[i].Ready = !g.tripped
+		}
+	}
+
+	g.mu.Unlock()
+
+	if g.cfg.OnTransition != nil {
+		for _, tr := range transitions {
+			g.cfg.OnTransition(tr)
+		}
+	}
+}
+
+// apply updates state of resource, must be called with mu held
+func (g *Guard) apply(transitions []Transition, res Resource, value, limit, high, low int64) []Transition {
+	st := g.states[res]
+	st.value, st.limit = value, limit
+
+	g.usage.WithLabelValues(string(res)).Set(float64(value))
+
+	switch {
+	case !st.overloaded && value >= high:
+		st.overloaded = true
+	case st.overloaded && value <= low:
+		st.overloaded = false
+	default:
+		return transitions
+	}
+
+	state := stateRecovered
+	overloaded := 0.0
+
+	if st.overloaded {
+		state = stateOverloaded
+		overloaded = 1
+	}
+
+	g.transitions.WithLabelValues(string(res), state).Inc()
+	g.overloaded.WithLabelValues(string(res)).Set(overloaded)
+
+	return append(transitions, Transition{
+		Resource:   res,
+		Overloaded: st.overloaded,
+		Value:      value,
+		Limit:      limit,
+		At:         time.Now(),
+	})
+}
+
+// updateReadiness toggles readiness flag, must be called with mu held.
+// Readiness is only restored if it was taken away by the guard.
+func (g *Guard) updateReadiness() {
+	overloaded := false
+
+	for _, st := range g.states {
+		if st.overloaded {
+			overloaded = true
+			break
+		}
+	}
+
+	switch {
+	case overloaded && !g.tripped:
+		g.tripped = true
+
+		if g.ready != nil {
+			g.ready.Store(false)
+		}
+	case !overloaded && g.tripped:
+		g.tripped = false
+
+		if g.ready != nil {
+			g.ready.Store(true)
+		}
+	}
+}
+
+// readMemoryUsage returns memory accounted against GOMEMLIMIT
+func readMemoryUsage() int64 {
+	samples := []metrics.Sample{
+		{Name: metricMemoryTotal},
+		{Name: metricMemoryReleased},
+	}
+
+	metrics.Read(samples)
+
+	var values [2]uint64
+
+	for i, s := range samples {
+		if s.Value.Kind() == metrics.KindUint64 {
+			values[i] = s.Value.Uint64()
+		}
+	}
+
+	return int64(values[0] - values[1])
+}
@@ -0,0 +1,119 @@
+package resourceguard
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestGuard(cfg Config, memory, goroutines *int64) *Guard {
+	g := New(cfg)
+	g.memoryUsage = func() int64 { return *memory }
+	g.memoryLimit = func() int64 { return 1000 }
+	g.goroutineCount = func() int64 { return *goroutines }
+
+	return g
+}
+
+func TestGuard_MemoryHysteresis(t *testing.T) {
+	var (
+		memory, goroutines int64
+		transitions        []Transition
+		ready              atomic.Bool
+	)
+
+	g := newTestGuard(Config{
+		MemoryHigh:   0.9,
+		MemoryLow:    0.7,
+		OnTransition: func(tr Transition) { transitions = append(transitions, tr) },
+	}, &memory, &goroutines)
+	g.ready = &ready
+	ready.Store(true)
+
+	memory = 500
+	g.Check()
+	assert.True(t, ready.Load())
+	assert.Empty(t, transitions)
+
+	memory = 950
+	g.Check()
+	assert.False(t, ready.Load())
+	assert.True(t, g.Overloaded())
+	require.Len(t, transitions, 1)
+	assert.Equal(t, ResourceMemory, transitions[0].Resource)
+	assert.True(t, transitions[0].Overloaded)
+	assert.False(t, transitions[0].Ready)
+
+	// between watermarks: still overloaded
+	memory = 800
+	g.Check()
+	assert.False(t, ready.Load())
+	assert.Len(t, transitions, 1)
+
+	memory = 600
+	g.Check()
+	assert.True(t, ready.Load())
+	require.Len(t, transitions, 2)
+	assert.False(t, transitions[1].Overloaded)
+	assert.True(t, transitions[1].Ready)
+
+	assert.InDelta(t, 1, testutil.ToFloat64(g.transitions.WithLabelValues("memory", stateOverloaded)), 0)
+	assert.InDelta(t, 1, testutil.ToFloat64(g.transitions.WithLabelValues("memory", stateRecovered)), 0)
+}
+
+func TestGuard_RecoversOnlyWhenAllResourcesRecovered(t *testing.T) {
+	var (
+		memory, goroutines int64
+		ready              atomic.Bool
+	)
+
+	g := newTestGuard(Config{
+		MemoryHigh:     0.9,
+		MemoryLow:      0.7,
+		GoroutinesHigh: 100,
+		GoroutinesLow:  50,
+	}, &memory, &goroutines)
+	g.ready = &ready
+	ready.Store(true)
+
+	memory, goroutines = 950, 200
+	g.Check()
+	assert.False(t, ready.Load())
+
+	memory = 100
+	g.Check()
+	assert.False(t, ready.Load(), "goroutines are still overloaded")
+
+	goroutines = 10
+	g.Check()
+	assert.True(t, ready.Load())
+}
+
+func TestGuard_DoesNotRestoreForeignUnreadiness(t *testing.T) {
+	var (
+		memory, goroutines int64
+		ready              atomic.Bool
+	)
+
+	g := newTestGuard(Config{GoroutinesHigh: 100}, &memory, &goroutines)
+	g.ready = &ready
+
+	goroutines = 10
+	g.Check()
+	assert.False(t, ready.Load(), "guard must not mark app ready if it did not take readiness away")
+}
+
+func TestGuard_StartStop(t *testing.T) {
+	var ready atomic.Bool
+
+	g := New(Config{GoroutinesHigh: 1 << 30})
+	require.NoError(t, prometheus.NewRegistry().Register(g.transitions))
+
+	g.Start(&ready)
+	g.Stop()
+	g.Stop()
+}
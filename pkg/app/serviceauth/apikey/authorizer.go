@@ -0,0 +1,242 @@
+// Package apikey implements ds.Authorizer for static API keys used in
+// service-to-service calls.
+//
+// Keys are stored in onlineconf as a JSON list:
+//
+//	[
+//	  {"name": "billing", "key": "s3cr3t-v1", "actor_id": 1001},
+//	  {"name": "billing", "key": "s3cr3t-v2", "actor_id": 1001}
+//	]
+//
+// The list is reloaded on every onlineconf update. To rotate a key add the new
+// one with the same name, switch clients and remove the old one: both keys are
+// accepted during the overlap. Metrics are labeled by key name, never by key value.
+package apikey
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/Educentr/go-onlineconf/pkg/onlineconf"
+	"github.com/go-faster/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+)
+
+// Name of the authorizer in metrics
+const Name = "api_key"
+
+const defaultHeader = "X-Api-Key"
+
+var (
+	errEmptyKey     = errors.New("key is empty")
+	errInvalidActor = errors.New("actor_id must not be negative")
+)
+
+// Key is an API key entry
+type Key struct {
+	// Name identifies the client, it is used in metrics instead of the key
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	// ActorID of the service actor, it is identified by Name if zero
+	ActorID int64 `json:"actor_id"`
+}
+
+// Config of the API key authorizer
+type Config struct {
+	// Header with the key, "X-Api-Key" by default
+	Header string
+
+	// ConfigPath is the onlineconf path of the key list. When empty only Keys are used.
+	ConfigPath string
+
+	// Keys are used when ConfigPath is empty or not set in onlineconf
+	Keys []Key
+}
+
+type storedKey struct {
+	name    string
+	hash    [sha256.Size]byte
+	actorID int64
+}
+
+// Authorizer checks API keys in constant time
+type Authorizer struct {
+	cfg     Config
+	keys    atomic.Pointer[[]storedKey]
+	metrics *serviceauth.Metrics
+	usage   *prometheus.CounterVec
+}
+
+var _ ds.Authorizer = (*Authorizer)(nil)
+
+// New creates the authorizer, keys are loaded in Init
+func New(cfg Config) *Authorizer {
+	if cfg.Header == "" {
+		cfg.Header = defaultHeader
+	}
+
+	return &Authorizer{
+		cfg: cfg,
+		usage: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Subsystem: "auth",
+				Name:      "api_key_usage_total",
+				Help:      "Total number of successful authentications by API key name",
+			},
+			[]string{"key_name"},
+		),
+	}
+}
+
+// Init loads keys and subscribes to onlineconf updates of ConfigPath
+func (a *Authorizer) Init(ctx context.Context, _ []ds.Runnable, m *prometheus.Registry) (ds.Authorizer, error) {
+	if err := a.reload(ctx); err != nil {
+		return nil, err
+	}
+
+	if a.cfg.ConfigPath != "" {
+		err := onlineconf.RegisterSubscription(ctx, onlineconf.DefaultModule, []string{a.cfg.ConfigPath}, func() error {
+			return a.reload(ctx)
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "can't subscribe to api keys update")
+		}
+	}
+
+	metrics, err := serviceauth.NewMetrics(m)
+	if err != nil {
+		return nil, err
+	}
+
+	a.metrics = metrics
+
+	if a.usage, err = serviceauth.Register(m, a.usage); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// reload reads keys from onlineconf, on error the previous set is kept
+func (a *Authorizer) reload(ctx context.Context) error {
+	keys := a.cfg.Keys
+
+	if a.cfg.ConfigPath != "" {
+		var fromConfig []Key
+
+		found, err := onlineconf.GetStruct(ctx, a.cfg.ConfigPath, &fromConfig)
+		if err != nil {
+			return errors.Wrapf(err, "can't read api keys from %s", a.cfg.ConfigPath)
+		}
+
+		if found {
+			keys = fromConfig
+		}
+	}
+
+	return a.SetKeys(keys)
+}
+
+// SetKeys atomically replaces the key set
+func (a *Authorizer) SetKeys(keys []Key) error {
+	stored := make([]storedKey, 0, len(keys))
+
+	for _, k := range keys {
+		if k.Key == "" {
+			return errors.Wrapf(errEmptyKey, "key %q", k.Name)
+		}
+
+		if k.ActorID < 0 {
+			return errors.Wrapf(errInvalidActor, "key %q", k.Name)
+		}
+
+		stored = append(stored, storedKey{
+			name:    k.Name,
+			hash:    sha256.Sum256([]byte(k.Key)),
+			actorID: k.ActorID,
+		})
+	}
+
+	a.keys.Store(&stored)
+
+	return nil
+}
+
+func (a *Authorizer) AuthRest(r *http.Request) (ds.Actor, error) {
+	act, err := a.authenticate(r.Header.Get(a.cfg.Header))
+	a.metrics.ObserveRequest(r, Name, act, err)
+
+	return act, err
+}
+
+// CheckCSRF always succeeds: API keys are not attached by browsers automatically
+func (a *Authorizer) CheckCSRF(_ *http.Request) (bool, error) {
+	return true, nil
+}
+
+// HasCredentials reports whether the request carries an API key
+func (a *Authorizer) HasCredentials(r *http.Request) bool {
+	return r.Header.Get(a.cfg.Header) != ""
+}
+
+// Authenticate checks raw key and maps it to an actor
+func (a *Authorizer) Authenticate(key string) (ds.Actor, error) {
+	act, err := a.authenticate(key)
+	a.metrics.Observe(Name, act, err)
+
+	return act, err
+}
+
+func (a *Authorizer) authenticate(key string) (ds.Actor, error) {
+	if key == "" {
+		return nil, serviceauth.NoCredentials()
+	}
+
+	found := a.match(key)
+	if found == nil {
+		return nil, serviceauth.Invalid(serviceauth.ReasonUnknownKey, nil)
+	}
+
+	a.usage.WithLabelValues(found.name).Inc()
+
+	return serviceActor(found.actorID, found.name), nil
+}
+
+// serviceActor returns the client of the key, identified by the key name if
+// it has no actor ID
+func serviceActor(id int64, name string) *actor.ServiceActor {
+	act := &actor.ServiceActor{Actor: actor.Actor{ID: id, Kind: actor.KindService, Name: name}, Service: name}
+	if id == 0 {
+		act.StringID = name
+	}
+
+	return act
+}
+
+// match compares hashes of all keys without early exit, so timing does not
+// depend on the position or the prefix of the matching key
+func (a *Authorizer) match(key string) *storedKey {
+	keys := a.keys.Load()
+	if keys == nil {
+		return nil
+	}
+
+	hash := sha256.Sum256([]byte(key))
+
+	var found *storedKey
+
+	for i := range *keys {
+		if subtle.ConstantTimeCompare(hash[:], (*keys)[i].hash[:]) == 1 {
+			found = &(*keys)[i]
+		}
+	}
+
+	return found
+}
@@ -0,0 +1,135 @@
+package apikey
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/Educentr/go-onlineconf/pkg/onlineconf"
+	"github.com/colinmarc/cdb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
+)
+
+const keysPath = "/test/api-keys"
+
+func onlineconfContext(t *testing.T, values map[string]string) context.Context {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	writer, err := cdb.Create(filepath.Join(dir, onlineconf.DefaultModule+".cdb"))
+	require.NoError(t, err)
+
+	for k, v := range values {
+		require.NoError(t, writer.Put([]byte(k), []byte(v)))
+	}
+
+	require.NoError(t, writer.Close())
+
+	ctx, err := onlineconf.Initialize(context.Background(), onlineconf.WithConfigDir(dir))
+	require.NoError(t, err)
+
+	return ctx
+}
+
+func auth(a *Authorizer, key string) (int64, error) {
+	r := httptest.NewRequest("GET", "/", nil)
+	if key != "" {
+		r.Header.Set("X-Api-Key", key)
+	}
+
+	act, err := a.AuthRest(r)
+	if err != nil {
+		return 0, err
+	}
+
+	return act.GetID(), nil
+}
+
+func TestAuthorizer_FromOnlineconf(t *testing.T) {
+	ctx := onlineconfContext(t, map[string]string{
+		keysPath: `j[{"name":"billing","key":"key-v1","actor_id":1001},{"name":"crm","key":"crm-key","actor_id":1002}]`,
+	})
+
+	a := New(Config{ConfigPath: keysPath})
+	_, err := a.Init(ctx, nil, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	id, err := auth(a, "key-v1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1001), id)
+
+	id, err = auth(a, "crm-key")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1002), id)
+
+	_, err = auth(a, "")
+	require.ErrorIs(t, err, serviceauth.ErrNoCredentials)
+
+	_, err = auth(a, "key-v")
+	require.ErrorIs(t, err, serviceauth.ErrInvalidCredentials)
+
+	assert.InDelta(t, 1, testutil.ToFloat64(a.usage.WithLabelValues("billing")), 0)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Api-Key", "crm-key")
+
+	act, err := a.AuthRest(r)
+	require.NoError(t, err)
+	assert.Equal(t, actor.KindService, actor.KindOf(act))
+	assert.Equal(t, "crm", act.(*actor.ServiceActor).GetName())
+}
+
+func TestAuthorizer_KeyWithoutActorID(t *testing.T) {
+	a := New(Config{Keys: []Key{{Name: "crm", Key: "crm-key"}}})
+	_, err := a.Init(context.Background(), nil, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Api-Key", "crm-key")
+
+	act, err := a.AuthRest(r)
+	require.NoError(t, err)
+	assert.Equal(t, "crm", act.(*actor.ServiceActor).GetStringID())
+
+	_, err = reqctx.SetActor(context.Background(), act)
+	require.NoError(t, err)
+
+	require.ErrorIs(t, a.SetKeys([]Key{{Name: "crm", Key: "crm-key", ActorID: -1}}), errInvalidActor)
+}
+
+func TestAuthorizer_Rotation(t *testing.T) {
+	a := New(Config{Keys: []Key{{Name: "billing", Key: "key-v1", ActorID: 1001}}})
+	_, err := a.Init(context.Background(), nil, nil)
+	require.NoError(t, err)
+
+	// overlap: both keys are valid
+	require.NoError(t, a.SetKeys([]Key{
+		{Name: "billing", Key: "key-v1", ActorID: 1001},
+		{Name: "billing", Key: "key-v2", ActorID: 1001},
+	}))
+
+	_, err = auth(a, "key-v1")
+	require.NoError(t, err)
+	_, err = auth(a, "key-v2")
+	require.NoError(t, err)
+
+	require.NoError(t, a.SetKeys([]Key{{Name: "billing", Key: "key-v2", ActorID: 1001}}))
+
+	_, err = auth(a, "key-v1")
+	require.ErrorIs(t, err, serviceauth.ErrInvalidCredentials)
+
+	// invalid set is rejected and the previous one is kept
+	require.Error(t, a.SetKeys([]Key{{Name: "billing", ActorID: 1001}}))
+
+	_, err = auth(a, "key-v2")
+	require.NoError(t, err)
+}
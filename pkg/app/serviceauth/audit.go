@@ -0,0 +1,220 @@
+package serviceauth
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-faster/errors"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
+)
+
+// ImpersonationAuthorizer is the authorizer of impersonation audit events
+const ImpersonationAuthorizer = "impersonation"
+
+// Transports of audit events
+const (
+	TransportHTTP     = "http"
+	TransportGRPC     = "grpc"
+	TransportTelegram = "telegram"
+)
+
+// AuditEvent is an authentication decision. It never contains credentials.
+type AuditEvent struct {
+	Time       time.Time `json:"time"`
+	Authorizer string    `json:"authorizer"`
+	Transport  string    `json:"transport,omitempty"`
+	Success    bool      `json:"success"`
+	// Reason of the failure, see Reason* constants
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+
+	ActorKind string `json:"actor_kind,omitempty"`
+	ActorID   int64  `json:"actor_id,omitempty"`
+	ActorSID  string `json:"actor_sid,omitempty"`
+	// RealActorID and RealActorSID are the actor acting on behalf of ActorID, see reqctx.Impersonate
+	RealActorID  int64  `json:"real_actor_id,omitempty"`
+	RealActorSID string `json:"real_actor_sid,omitempty"`
+
+	ClientIP  string `json:"client_ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Auditor receives audit events. Audit must not block, see package audit for
+// an asynchronous pipeline.
+type Auditor interface {
+	Audit(event AuditEvent)
+}
+
+var globalAuditor Auditor // nil by default, events are discarded
+
+// SetAuditor sets the auditor of authentication decisions, impersonations are
+// audited too. This should be called once during application initialization.
+func SetAuditor(a Auditor) {
+	globalAuditor = a
+
+	if a != nil {
+		reqctx.SetImpersonationObserver(AuditImpersonation)
+	}
+}
+
+type ctxKey int
+
+const (
+	transportField ctxKey = iota
+	chainField
+)
+
+// WithTransport marks ctx with the transport name reported in audit events
+func WithTransport(ctx context.Context, transport string) context.Context {
+	return context.WithValue(ctx, transportField, transport)
+}
+
+// transportOf returns the transport of ctx, def if it is not set
+func transportOf(ctx context.Context, def string) string {
+	if transport, ok := ctx.Value(transportField).(string); ok {
+		return transport
+	}
+
+	return def
+}
+
+// WithinChain marks ctx of a request passed to authorizers of a chain. Their
+// failures for absent credentials are not audited: another authorizer may
+// accept the request, the chain reports it by AuditNoCredentials otherwise.
+func WithinChain(ctx context.Context) context.Context {
+	return context.WithValue(ctx, chainField, true)
+}
+
+// skipAudit reports whether the decision is not audited: there is no auditor
+// or credentials are absent in the request of a chain
+func skipAudit(ctx context.Context, err error) bool {
+	if globalAuditor == nil {
+		return true
+	}
+
+	within, _ := ctx.Value(chainField).(bool)
+
+	return within && errors.Is(err, ErrNoCredentials)
+}
+
+// AuditRequest reports the decision of authorizer on r, see WithinChain
+func AuditRequest(r *http.Request, authorizer string, act ds.Actor, err error) {
+	if skipAudit(r.Context(), err) {
+		return
+	}
+
+	event := newAuditEvent(r.Context(), TransportHTTP, authorizer, act, err)
+	event.ClientIP = remoteIP(r)
+	event.UserAgent = r.UserAgent()
+
+	globalAuditor.Audit(event)
+}
+
+// AuditContext reports the decision of authorizer without a request, e.g.
+// for tokens passed to Authenticate, see WithinChain
+func AuditContext(ctx context.Context, authorizer string, act ds.Actor, err error) {
+	if skipAudit(ctx, err) {
+		return
+	}
+
+	globalAuditor.Audit(newAuditEvent(ctx, "", authorizer, act, err))
+}
+
+// AuditNoCredentials reports a request none of the authorizers has credentials
+// for, ctx is the context of r if r is nil. Nested chains don't report it.
+func AuditNoCredentials(ctx context.Context, r *http.Request, authorizer string) {
+	transport := ""
+
+	if r != nil {
+		ctx = r.Context()
+		transport = TransportHTTP
+	}
+
+	if skipAudit(ctx, NoCredentials()) {
+		return
+	}
+
+	event := newAuditEvent(ctx, transport, authorizer, nil, NoCredentials())
+
+	if r != nil {
+		event.ClientIP = remoteIP(r)
+		event.UserAgent = r.UserAgent()
+	}
+
+	globalAuditor.Audit(event)
+}
+
+// AuditImpersonation reports the decision of reqctx.Impersonate, it is the
+// reqctx.ImpersonationObserver set by SetAuditor
+func AuditImpersonation(ctx context.Context, real, effective ds.Actor, err error) {
+	if globalAuditor == nil {
+		return
+	}
+
+	event := newAuditEvent(ctx, "", ImpersonationAuthorizer, effective, err)
+
+	if errors.Is(err, reqctx.ErrImpersonationDenied) {
+		event.Reason = ReasonImpersonationDenied
+	}
+
+	if real != nil {
+		event.RealActorID, event.RealActorSID = actorIDs(real)
+	}
+
+	globalAuditor.Audit(event)
+}
+
+func newAuditEvent(ctx context.Context, transport, authorizer string, act ds.Actor, err error) AuditEvent {
+	event := AuditEvent{
+		Time:       time.Now(),
+		Authorizer: authorizer,
+		Transport:  transportOf(ctx, transport),
+		Success:    err == nil,
+		Reason:     ReasonOf(err),
+	}
+
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	if act != nil {
+		event.ActorKind = string(actor.KindOf(act))
+		event.ActorID, event.ActorSID = actorIDs(act)
+	}
+
+	if rid, ridErr := reqctx.GetRequestID(ctx); ridErr == nil {
+		event.RequestID = rid
+	}
+
+	if reqctx.IsImpersonated(ctx) {
+		if real, realErr := reqctx.GetRealActor(ctx); realErr == nil {
+			event.RealActorID, event.RealActorSID = actorIDs(real)
+		}
+	}
+
+	return event
+}
+
+// actorIDs returns numeric and string IDs of act
+func actorIDs(act ds.Actor) (int64, string) {
+	if p, ok := act.(actor.StringIDProvider); ok {
+		return act.GetID(), p.GetStringID()
+	}
+
+	return act.GetID(), ""
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
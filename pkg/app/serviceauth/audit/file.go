@@ -0,0 +1,189 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/go-faster/errors"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+)
+
+const (
+	defaultMaxSize    = 100 << 20
+	defaultMaxBackups = 5
+	defaultFileMode   = 0o600
+)
+
+// FileConfig of FileSink
+type FileConfig struct {
+	Path string
+
+	// MaxSize of the file in bytes before rotation, 100 MiB by default
+	MaxSize int64
+
+	// MaxBackups is the number of rotated files kept as Path.1 ... Path.N, 5 by default
+	MaxBackups int
+
+	// Mode of created files, 0600 by default
+	Mode os.FileMode
+}
+
+// FileSink writes events as JSON lines and rotates the file by size
+type FileSink struct {
+	cfg FileConfig
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+var _ Sink = (*FileSink)(nil)
+
+// NewFileSink opens or creates the file for appending
+func NewFileSink(cfg FileConfig) (*FileSink, error) {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = defaultMaxSize
+	}
+
+	if cfg.MaxBackups <= 0 {
+		cfg.MaxBackups = defaultMaxBackups
+	}
+
+	if cfg.Mode == 0 {
+		cfg.Mode = defaultFileMode
+	}
+
+	s := &FileSink{cfg: cfg}
+
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *FileSink) Name() string {
+	return "file"
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, s.cfg.Mode)
+	if err != nil {
+		return errors.Wrap(err, "open audit file")
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "stat audit file")
+	}
+
+	s.file = f
+	s.size = info.Size()
+
+	return nil
+}
+
+// Write appends events, the file is rotated before a batch which doesn't fit
+func (s *FileSink) Write(events []serviceauth.AuditEvent) error {
+	var buf []byte
+
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return errors.Wrap(err, "marshal audit event")
+		}
+
+		buf = append(append(buf, line...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return os.ErrClosed
+	}
+
+	var rotateErr error
+
+	if s.size > 0 && s.size+int64(len(buf)) > s.cfg.MaxSize {
+		// events are still appended to Path if only the rotation failed
+		if rotateErr = s.rotate(); s.file == nil {
+			return rotateErr
+		}
+	}
+
+	n, err := s.file.Write(buf)
+	s.size += int64(n)
+
+	if err != nil {
+		return errors.Join(rotateErr, errors.Wrap(err, "write audit file"))
+	}
+
+	return rotateErr
+}
+
+// rotate shifts Path.N-1 to Path.N, ..., Path to Path.1 and reopens Path.
+// Path is reopened for appending if the rotation fails, so the sink is not
+// left closed.
+func (s *FileSink) rotate() error {
+	err := s.file.Close()
+	s.file = nil
+
+	if err != nil {
+		err = errors.Wrap(err, "close audit file")
+	} else {
+		err = s.shift()
+	}
+
+	if err != nil {
+		if openErr := s.open(); openErr != nil {
+			return errors.Join(err, openErr)
+		}
+
+		return err
+	}
+
+	return s.open()
+}
+
+func (s *FileSink) shift() error {
+	for i := s.cfg.MaxBackups - 1; i >= 1; i-- {
+		err := os.Rename(backupName(s.cfg.Path, i), backupName(s.cfg.Path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "rotate audit file")
+		}
+	}
+
+	if err := os.Rename(s.cfg.Path, backupName(s.cfg.Path, 1)); err != nil {
+		return errors.Wrap(err, "rotate audit file")
+	}
+
+	return nil
+}
+
+func backupName(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
+
+// Close closes the file
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+
+	if err != nil {
+		return errors.Wrap(err, "close audit file")
+	}
+
+	return nil
+}
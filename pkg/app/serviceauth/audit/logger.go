@@ -0,0 +1,53 @@
+package audit
+
+import (
+	zlog "github.com/rs/zerolog"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+)
+
+// LoggerSink writes events to zerolog: successes at info level, failures at warn level
+type LoggerSink struct {
+	logger *zlog.Logger
+}
+
+var _ Sink = (*LoggerSink)(nil)
+
+// NewLoggerSink creates the sink writing to logger
+func NewLoggerSink(logger *zlog.Logger) *LoggerSink {
+	return &LoggerSink{logger: logger}
+}
+
+func (s *LoggerSink) Name() string {
+	return "logger"
+}
+
+func (s *LoggerSink) Write(events []serviceauth.AuditEvent) error {
+	for _, e := range events {
+		entry := s.logger.Info()
+		if !e.Success {
+			entry = s.logger.Warn().Str("reason", e.Reason).Str("error", e.Error)
+		}
+
+		entry.
+			Time("time", e.Time).
+			Str("authorizer", e.Authorizer).
+			Str("transport", e.Transport).
+			Bool("success", e.Success).
+			Str("actor_kind", e.ActorKind).
+			Int64("actor_id", e.ActorID).
+			Str("actor_sid", e.ActorSID).
+			Int64("real_actor_id", e.RealActorID).
+			Str("real_actor_sid", e.RealActorSID).
+			Str("client_ip", e.ClientIP).
+			Str("user_agent", e.UserAgent).
+			Str("request_id", e.RequestID).
+			Msg("auth audit")
+	}
+
+	return nil
+}
+
+func (s *LoggerSink) Close() error {
+	return nil
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"sync"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+)
+
+// MemorySink keeps events in memory, it is meant for tests
+type MemorySink struct {
+	mu     sync.Mutex
+	events []serviceauth.AuditEvent
+}
+
+var _ Sink = (*MemorySink)(nil)
+
+// NewMemorySink creates an empty sink
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (s *MemorySink) Name() string {
+	return "memory"
+}
+
+func (s *MemorySink) Write(events []serviceauth.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, events...)
+
+	return nil
+}
+
+func (s *MemorySink) Close() error {
+	return nil
+}
+
+// Events returns a copy of written events
+func (s *MemorySink) Events() []serviceauth.AuditEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]serviceauth.AuditEvent(nil), s.events...)
+}
+
+// Reset removes written events
+func (s *MemorySink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = nil
+}
@@ -0,0 +1,205 @@
+// Package audit delivers serviceauth.AuditEvent to sinks asynchronously.
+//
+// Pipeline implements serviceauth.Auditor: Audit never blocks, events which
+// don't fit into the bounded buffer are dropped and counted. The pipeline is a
+// ds.Runnable, register it as a driver so it is flushed during graceful stop
+// after transports:
+//
+//	p := audit.New(audit.Config{Sinks: []audit.Sink{fileSink}})
+//	serviceauth.SetAuditor(p)
+//	_ = application.SetDriver(p)
+package audit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-faster/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+)
+
+// Name of the pipeline driver
+const Name = "auth_audit"
+
+const (
+	defaultBufferSize = 4096
+	defaultBatchSize  = 256
+
+	dropBufferFull = "buffer_full"
+	dropStopped    = "stopped"
+)
+
+// Sink stores events. Write is called from the single pipeline goroutine.
+type Sink interface {
+	// Name is used in metrics
+	Name() string
+	Write(events []serviceauth.AuditEvent) error
+	Close() error
+}
+
+// Config of the pipeline
+type Config struct {
+	Sinks []Sink
+
+	// BufferSize is the number of events waiting for sinks, 4096 by default
+	BufferSize int
+
+	// BatchSize is the maximal number of events written at once, 256 by default
+	BatchSize int
+}
+
+// Pipeline buffers events and writes them to sinks in the background
+type Pipeline struct {
+	*ds.LoopRunnable
+
+	cfg    Config
+	events chan serviceauth.AuditEvent
+
+	// mu orders sends of Audit before the final drain
+	mu      sync.RWMutex
+	stopped bool
+
+	dropped    *prometheus.CounterVec
+	sinkErrors *prometheus.CounterVec
+}
+
+var (
+	_ serviceauth.Auditor = (*Pipeline)(nil)
+	_ ds.Runnable         = (*Pipeline)(nil)
+)
+
+// New creates the pipeline, events are buffered until Run
+func New(cfg Config) *Pipeline {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaultBufferSize
+	}
+
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+
+	p := &Pipeline{
+		cfg:    cfg,
+		events: make(chan serviceauth.AuditEvent, cfg.BufferSize),
+		dropped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Subsystem: "auth",
+				Name:      "audit_dropped_total",
+				Help:      "Total number of audit events dropped by reason",
+			},
+			[]string{"reason"},
+		),
+		sinkErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Subsystem: "auth",
+				Name:      "audit_sink_errors_total",
+				Help:      "Total number of failed audit batch writes by sink",
+			},
+			[]string{"sink"},
+		),
+	}
+
+	p.LoopRunnable = ds.NewLoopRunnable(Name, p.loop)
+	p.InitFunc = func(_ context.Context, _ string, _ ds.ServerBucket, m *prometheus.Registry) error {
+		return p.register(m)
+	}
+
+	return p
+}
+
+func (p *Pipeline) register(m *prometheus.Registry) error {
+	var err error
+
+	if p.dropped, err = serviceauth.Register(m, p.dropped); err != nil {
+		return err
+	}
+
+	if p.sinkErrors, err = serviceauth.Register(m, p.sinkErrors); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Audit enqueues the event, it is dropped if the buffer is full or the pipeline is stopped
+func (p *Pipeline) Audit(event serviceauth.AuditEvent) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.stopped {
+		p.dropped.WithLabelValues(dropStopped).Inc()
+		return
+	}
+
+	select {
+	case p.events <- event:
+	default:
+		p.dropped.WithLabelValues(dropBufferFull).Inc()
+	}
+}
+
+// loop writes events until ctx is canceled, then flushes the buffer and closes sinks
+func (p *Pipeline) loop(ctx context.Context) error {
+	batch := make([]serviceauth.AuditEvent, 0, p.cfg.BatchSize)
+
+	for {
+		select {
+		case event := <-p.events:
+			batch = p.fill(append(batch[:0], event))
+			p.write(batch)
+		case <-ctx.Done():
+			// no event is enqueued after the lock, so the drain gets all of them
+			p.mu.Lock()
+			p.stopped = true
+			p.mu.Unlock()
+
+			for {
+				batch = p.fill(batch[:0])
+				if len(batch) == 0 {
+					break
+				}
+
+				p.write(batch)
+			}
+
+			return p.close()
+		}
+	}
+}
+
+// fill appends buffered events to batch without blocking
+func (p *Pipeline) fill(batch []serviceauth.AuditEvent) []serviceauth.AuditEvent {
+	for len(batch) < p.cfg.BatchSize {
+		select {
+		case event := <-p.events:
+			batch = append(batch, event)
+		default:
+			return batch
+		}
+	}
+
+	return batch
+}
+
+func (p *Pipeline) write(batch []serviceauth.AuditEvent) {
+	for _, sink := range p.cfg.Sinks {
+		if err := sink.Write(batch); err != nil {
+			p.sinkErrors.WithLabelValues(sink.Name()).Inc()
+		}
+	}
+}
+
+func (p *Pipeline) close() error {
+	var errs []error
+
+	for _, sink := range p.cfg.Sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, errors.Wrapf(err, "close audit sink %s", sink.Name()))
+		}
+	}
+
+	return errors.Join(errs...)
+}
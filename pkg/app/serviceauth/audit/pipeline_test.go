@@ -0,0 +1,186 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth/apikey"
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
+)
+
+func TestPipeline_Authorizer(t *testing.T) {
+	ctx := context.Background()
+	sink := NewMemorySink()
+	p := New(Config{Sinks: []Sink{sink}})
+
+	serviceauth.SetAuditor(p)
+	t.Cleanup(func() { serviceauth.SetAuditor(nil) })
+
+	a, err := apikey.New(apikey.Config{Keys: []apikey.Key{{Name: "billing", Key: "secret", ActorID: 7}}}).Init(ctx, nil, nil)
+	require.NoError(t, err)
+
+	var errGr errgroup.Group
+
+	p.Run(ctx, &errGr)
+
+	request := func(key string) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "192.0.2.1:5000"
+		r.Header.Set("User-Agent", "test-agent")
+
+		if key != "" {
+			r.Header.Set("X-Api-Key", key)
+		}
+
+		rctx, err := reqctx.SetRequestID(r.Context(), "rid-1")
+		require.NoError(t, err)
+
+		_, _ = a.AuthRest(r.WithContext(rctx))
+	}
+
+	request("secret")
+	request("wrong")
+	request("")
+
+	require.NoError(t, p.Shutdown(ctx))
+	require.NoError(t, errGr.Wait())
+
+	events := sink.Events()
+	require.Len(t, events, 3)
+
+	assert.True(t, events[0].Success)
+	assert.Equal(t, apikey.Name, events[0].Authorizer)
+	assert.Equal(t, serviceauth.TransportHTTP, events[0].Transport)
+	assert.Equal(t, int64(7), events[0].ActorID)
+	assert.Equal(t, "192.0.2.1", events[0].ClientIP)
+	assert.Equal(t, "test-agent", events[0].UserAgent)
+	assert.Equal(t, "rid-1", events[0].RequestID)
+
+	assert.False(t, events[1].Success)
+	assert.Equal(t, serviceauth.ReasonUnknownKey, events[1].Reason)
+	assert.Zero(t, events[1].ActorID)
+
+	// the authorizer is not in a chain, so requests without credentials are audited by it
+	assert.Equal(t, serviceauth.ReasonMissing, events[2].Reason)
+
+	// stopped pipeline drops events
+	p.Audit(serviceauth.AuditEvent{})
+	assert.InDelta(t, 1, testutil.ToFloat64(p.dropped.WithLabelValues(dropStopped)), 0)
+}
+
+func TestPipeline_StopCountsEveryEvent(t *testing.T) {
+	ctx := context.Background()
+	sink := NewMemorySink()
+	p := New(Config{Sinks: []Sink{sink}})
+
+	var errGr errgroup.Group
+
+	p.Run(ctx, &errGr)
+
+	const producers, perProducer = 8, 100
+
+	var wg sync.WaitGroup
+
+	for range producers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for range perProducer {
+				p.Audit(serviceauth.AuditEvent{Authorizer: "jwt"})
+			}
+		}()
+	}
+
+	require.NoError(t, p.Shutdown(ctx))
+	require.NoError(t, errGr.Wait())
+	wg.Wait()
+
+	dropped := testutil.ToFloat64(p.dropped.WithLabelValues(dropStopped)) + testutil.ToFloat64(p.dropped.WithLabelValues(dropBufferFull))
+	assert.InDelta(t, producers*perProducer, float64(len(sink.Events()))+dropped, 0)
+}
+
+func TestPipeline_BufferFull(t *testing.T) {
+	p := New(Config{BufferSize: 1})
+
+	p.Audit(serviceauth.AuditEvent{Authorizer: "a"})
+	p.Audit(serviceauth.AuditEvent{Authorizer: "b"})
+
+	assert.InDelta(t, 1, testutil.ToFloat64(p.dropped.WithLabelValues(dropBufferFull)), 0)
+}
+
+func TestFileSink_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	s, err := NewFileSink(FileConfig{Path: path, MaxSize: 150, MaxBackups: 2})
+	require.NoError(t, err)
+
+	for i := range 5 {
+		require.NoError(t, s.Write([]serviceauth.AuditEvent{{Authorizer: "jwt", ActorID: int64(i + 1), Success: true}}))
+	}
+
+	require.NoError(t, s.Close())
+
+	lines := func(name string) []serviceauth.AuditEvent {
+		f, err := os.Open(name)
+		require.NoError(t, err)
+
+		defer f.Close()
+
+		var events []serviceauth.AuditEvent
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var e serviceauth.AuditEvent
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+			events = append(events, e)
+		}
+
+		return events
+	}
+
+	current := lines(path)
+	require.NotEmpty(t, current)
+	assert.Equal(t, int64(5), current[len(current)-1].ActorID)
+	assert.NotEmpty(t, lines(path+".1"))
+	assert.NotEmpty(t, lines(path+".2"))
+	assert.NoFileExists(t, path+".3")
+}
+
+func TestFileSink_RotationFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+
+	// a non-empty directory in place of the backup makes the rename fail
+	require.NoError(t, os.MkdirAll(filepath.Join(path+".1", "busy"), 0o700))
+
+	s, err := NewFileSink(FileConfig{Path: path, MaxSize: 100, MaxBackups: 1})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = s.Close() })
+
+	event := []serviceauth.AuditEvent{{Authorizer: "jwt", ActorID: 1, Success: true}}
+
+	require.NoError(t, s.Write(event))
+	require.Error(t, s.Write(event), "rotation fails")
+	require.Error(t, s.Write(event), "rotation is retried")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, bytes.Count(data, []byte("\n")), "events are appended to the file")
+}
@@ -0,0 +1,79 @@
+package serviceauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
+)
+
+type auditRecorder []AuditEvent
+
+func (r *auditRecorder) Audit(event AuditEvent) {
+	*r = append(*r, event)
+}
+
+func TestAuditImpersonation(t *testing.T) {
+	var events auditRecorder
+
+	SetAuditor(&events)
+	t.Cleanup(func() {
+		SetAuditor(nil)
+		reqctx.SetImpersonationObserver(nil)
+	})
+
+	support := &actor.Actor{ID: 1}
+	user := &actor.Actor{ID: 2}
+	ctx := context.Background()
+
+	_, err := reqctx.Impersonate(ctx, support, user)
+	require.ErrorIs(t, err, reqctx.ErrImpersonationDenied)
+
+	reqctx.SetImpersonationPolicy(func(context.Context, ds.Actor, ds.Actor) error { return nil })
+	t.Cleanup(func() { reqctx.SetImpersonationPolicy(nil) })
+
+	_, err = reqctx.Impersonate(ctx, support, user)
+	require.NoError(t, err)
+
+	require.Len(t, events, 2)
+
+	for _, e := range events {
+		assert.Equal(t, ImpersonationAuthorizer, e.Authorizer)
+		assert.Equal(t, int64(2), e.ActorID)
+		assert.Equal(t, int64(1), e.RealActorID)
+	}
+
+	assert.False(t, events[0].Success)
+	assert.Equal(t, ReasonImpersonationDenied, events[0].Reason)
+	assert.True(t, events[1].Success)
+
+	// real actors identified by string IDs
+	_, err = reqctx.Impersonate(ctx, &actor.Actor{Kind: actor.KindService, StringID: "crm"}, user)
+	require.NoError(t, err)
+
+	require.Len(t, events, 3)
+	assert.Equal(t, "crm", events[2].RealActorSID)
+}
+
+func TestAuditRequest_NoCredentials(t *testing.T) {
+	var events auditRecorder
+
+	SetAuditor(&events)
+	t.Cleanup(func() { SetAuditor(nil) })
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	AuditRequest(r, "jwt", nil, NoCredentials())
+	AuditRequest(r.WithContext(WithinChain(r.Context())), "jwt", nil, NoCredentials())
+	AuditNoCredentials(WithinChain(context.Background()), nil, "nested")
+
+	require.Len(t, events, 1, "absent credentials are reported by the chain")
+	assert.Equal(t, ReasonMissing, events[0].Reason)
+}
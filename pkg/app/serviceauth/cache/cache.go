@@ -0,0 +1,395 @@
+// Package cache implements a caching decorator for ds.Authorizer.
+//
+// Actors are cached by a fingerprint of the request credentials (SHA-256 of
+// the value returned by KeyFunc, raw credentials are never kept) in a bounded
+// LRU with TTL. Rejected credentials are cached for a shorter NegativeTTL.
+// Concurrent requests with the same credentials share one call of the inner
+// authorizer. Actors implementing ExpiringActor are never served after their
+// expiry, whatever the TTL is. Decisions served from the cache or shared with a
+// concurrent request are audited as Name, the inner authorizer audits the rest.
+//
+// Cached actors are shared between requests and must not be modified, so the
+// decorator should wrap a chain.Authorizer rather than its entries. KeyFunc
+// must cover every credential the inner authorizer reads (combine them with
+// Keys), otherwise an actor authenticated by one credential would be served
+// to requests presenting only another one. Authorizers whose credentials are
+// unique per request (hmacauth) gain nothing from it.
+//
+//	cache.New(chain.New(jwtEntry, apiKeyEntry, sessionEntry), cache.Config{
+//		KeyFunc: cache.Keys(cache.HeaderKey("Authorization", "X-Api-Key"), cache.CookieKey("session")),
+//	})
+package cache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+)
+
+// Name of the authorizer in audit events
+const Name = "cache"
+
+var errNoKeyFunc = errors.New("cache key func is not set")
+
+const (
+	defaultName        = "default"
+	defaultTTL         = time.Minute
+	defaultNegativeTTL = 5 * time.Second
+	defaultSize        = 10000
+)
+
+// Lookup results and eviction reasons in metrics
+const (
+	resultHit         = "hit"
+	resultNegativeHit = "negative_hit"
+	resultMiss        = "miss"
+
+	evictionSize        = "size"
+	evictionExpired     = "expired"
+	evictionInvalidated = "invalidated"
+)
+
+// ExpiringActor is implemented by actors with known credentials expiry, e.g. actor.Actor
+type ExpiringActor interface {
+	GetExpiresAt() time.Time
+}
+
+// KeyFunc returns credentials of the request, false if there are none.
+// Requests without credentials bypass the cache.
+type KeyFunc func(r *http.Request) (string, bool)
+
+// Config of the cache
+type Config struct {
+	// Name is the "cache" label of metrics, "default" by default
+	Name string
+
+	// KeyFunc extracts all credentials read by the inner authorizer, required
+	KeyFunc KeyFunc
+
+	// TTL of successful results, 1 minute by default
+	TTL time.Duration
+	// NegativeTTL of rejected credentials, 5 seconds by default, negative disables negative caching
+	NegativeTTL time.Duration
+	// Size is the maximal number of entries, 10000 by default
+	Size int
+
+	// Now is used in tests
+	Now func() time.Time
+}
+
+type fingerprint [sha256.Size]byte
+
+// actorKey identifies actors of any kind, string IDs included
+type actorKey struct {
+	kind     actor.Kind
+	id       int64
+	stringID string
+}
+
+func keyOf(act ds.Actor) actorKey {
+	k := actorKey{kind: actor.KindOf(act), id: act.GetID()}
+
+	if p, ok := act.(actor.StringIDProvider); ok {
+		k.stringID = p.GetStringID()
+	}
+
+	return k
+}
+
+type entry struct {
+	key     fingerprint
+	act     ds.Actor
+	err     error
+	expires time.Time
+}
+
+// Authorizer caches results of the inner authorizer. CheckCSRF is delegated.
+type Authorizer struct {
+	ds.Authorizer
+	cfg Config
+
+	mu      sync.Mutex
+	lru     *list.List
+	items   map[fingerprint]*list.Element
+	byActor map[actorKey]map[fingerprint]struct{}
+
+	group singleflight.Group
+
+	requests  *prometheus.CounterVec
+	evictions *prometheus.CounterVec
+}
+
+var _ ds.Authorizer = (*Authorizer)(nil)
+
+// New wraps inner with the cache
+func New(inner ds.Authorizer, cfg Config) *Authorizer {
+	if cfg.Name == "" {
+		cfg.Name = defaultName
+	}
+
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultTTL
+	}
+
+	if cfg.NegativeTTL == 0 {
+		cfg.NegativeTTL = defaultNegativeTTL
+	}
+
+	if cfg.Size <= 0 {
+		cfg.Size = defaultSize
+	}
+
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	return &Authorizer{
+		Authorizer: inner,
+		cfg:        cfg,
+		lru:        list.New(),
+		items:      make(map[fingerprint]*list.Element),
+		byActor:    make(map[actorKey]map[fingerprint]struct{}),
+		requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Subsystem: "auth",
+				Name:      "cache_requests_total",
+				Help:      "Total number of authorizer cache lookups by result",
+			},
+			[]string{"cache", "result"},
+		),
+		evictions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Subsystem: "auth",
+				Name:      "cache_evictions_total",
+				Help:      "Total number of entries removed from authorizer cache by reason",
+			},
+			[]string{"cache", "reason"},
+		),
+	}
+}
+
+// Init initializes the inner authorizer and registers cache metrics
+func (a *Authorizer) Init(ctx context.Context, drvs []ds.Runnable, m *prometheus.Registry) (ds.Authorizer, error) {
+	if a.cfg.KeyFunc == nil {
+		return nil, errNoKeyFunc
+	}
+
+	inner, err := a.Authorizer.Init(ctx, drvs, m)
+	if err != nil {
+		return nil, err
+	}
+
+	a.Authorizer = inner
+
+	if a.requests, err = serviceauth.Register(m, a.requests); err != nil {
+		return nil, err
+	}
+
+	if a.evictions, err = serviceauth.Register(m, a.evictions); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *Authorizer) AuthRest(r *http.Request) (ds.Actor, error) {
+	credentials, ok := a.cfg.KeyFunc(r)
+	if !ok {
+		return a.Authorizer.AuthRest(r)
+	}
+
+	key := sha256.Sum256([]byte(credentials))
+
+	if e, ok := a.get(key); ok {
+		if e.err != nil {
+			a.requests.WithLabelValues(a.cfg.Name, resultNegativeHit).Inc()
+		} else {
+			a.requests.WithLabelValues(a.cfg.Name, resultHit).Inc()
+		}
+
+		serviceauth.AuditRequest(r, Name, e.act, e.err)
+
+		return e.act, e.err
+	}
+
+	a.requests.WithLabelValues(a.cfg.Name, resultMiss).Inc()
+
+	leader := false
+
+	v, err, _ := a.group.Do(string(key[:]), func() (any, error) {
+		leader = true
+
+		act, err := a.Authorizer.AuthRest(r)
+		a.put(key, act, err)
+
+		return act, err
+	})
+
+	act, _ := v.(ds.Actor)
+
+	// the inner authorizer has audited the request of the leader only
+	if !leader {
+		serviceauth.AuditRequest(r, Name, act, err)
+	}
+
+	return act, err
+}
+
+// AuthTelegram delegates to the inner authorizer without caching. gRPC calls
+// are served by AuthRest (see serviceauth.AuthGRPC) and are cached when
+// KeyFunc finds credentials in metadata.
+func (a *Authorizer) AuthTelegram(ctx context.Context, update ds.TelegramUpdate) (ds.Actor, error) {
+	return serviceauth.AuthTelegram(ctx, a.Authorizer, update)
+}
+
+// HasCredentials delegates to the inner authorizer
+func (a *Authorizer) HasCredentials(r *http.Request) bool {
+	return serviceauth.HasCredentials(a.Authorizer, r)
+}
+
+// Invalidate removes the entry of credentials as returned by KeyFunc, e.g. of a revoked token
+func (a *Authorizer) Invalidate(credentials string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	el, ok := a.items[sha256.Sum256([]byte(credentials))]
+	if ok {
+		a.remove(el, evictionInvalidated)
+	}
+
+	return ok
+}
+
+// InvalidateActor removes all entries of the actor with the same kind and ID
+// (numeric and string) as act and returns their number
+func (a *Authorizer) InvalidateActor(act ds.Actor) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	keys := a.byActor[keyOf(act)]
+	n := len(keys)
+
+	for key := range keys {
+		a.remove(a.items[key], evictionInvalidated)
+	}
+
+	return n
+}
+
+// Purge removes all entries
+func (a *Authorizer) Purge() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for a.lru.Len() > 0 {
+		a.remove(a.lru.Back(), evictionInvalidated)
+	}
+}
+
+// Len returns the number of entries
+func (a *Authorizer) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.lru.Len()
+}
+
+func (a *Authorizer) get(key fingerprint) (*entry, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	el, ok := a.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*entry)
+
+	if !a.cfg.Now().Before(e.expires) {
+		a.remove(el, evictionExpired)
+		return nil, false
+	}
+
+	a.lru.MoveToFront(el)
+
+	return e, true
+}
+
+func (a *Authorizer) put(key fingerprint, act ds.Actor, err error) {
+	now := a.cfg.Now()
+	e := &entry{key: key, act: act, err: err}
+
+	switch {
+	case err == nil && act != nil:
+		e.expires = now.Add(a.cfg.TTL)
+
+		if exp, ok := act.(ExpiringActor); ok && !exp.GetExpiresAt().IsZero() && exp.GetExpiresAt().Before(e.expires) {
+			e.expires = exp.GetExpiresAt()
+		}
+	case a.cfg.NegativeTTL > 0 && isRejection(err):
+		e.expires = now.Add(a.cfg.NegativeTTL)
+	default:
+		return
+	}
+
+	if !now.Before(e.expires) {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if el, ok := a.items[key]; ok {
+		a.remove(el, evictionInvalidated)
+	}
+
+	a.items[key] = a.lru.PushFront(e)
+
+	if act != nil && err == nil {
+		keys := a.byActor[keyOf(act)]
+		if keys == nil {
+			keys = make(map[fingerprint]struct{})
+			a.byActor[keyOf(act)] = keys
+		}
+
+		keys[key] = struct{}{}
+	}
+
+	for a.lru.Len() > a.cfg.Size {
+		a.remove(a.lru.Back(), evictionSize)
+	}
+}
+
+// remove deletes el, a.mu must be held
+func (a *Authorizer) remove(el *list.Element, reason string) {
+	e := a.lru.Remove(el).(*entry)
+	delete(a.items, e.key)
+
+	if e.act != nil && e.err == nil {
+		k := keyOf(e.act)
+		delete(a.byActor[k], e.key)
+
+		if len(a.byActor[k]) == 0 {
+			delete(a.byActor, k)
+		}
+	}
+
+	a.evictions.WithLabelValues(a.cfg.Name, reason).Inc()
+}
+
+// isRejection reports whether err rejects credentials. Internal failures are
+// transient and are not cached.
+func isRejection(err error) bool {
+	return errors.Is(err, serviceauth.ErrInvalidCredentials) && serviceauth.ReasonOf(err) != serviceauth.ReasonInternal
+}
@@ -0,0 +1,258 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app"
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+)
+
+// remote resolves tokens "user-<id>" and counts calls
+type remote struct {
+	app.UnimplementedAuthorizer
+	calls   atomic.Int32
+	block   chan struct{}
+	expires time.Time
+}
+
+func (s *remote) Init(_ context.Context, _ []ds.Runnable, _ *prometheus.Registry) (ds.Authorizer, error) {
+	return s, nil
+}
+
+func (s *remote) AuthRest(r *http.Request) (ds.Actor, error) {
+	s.calls.Add(1)
+
+	if s.block != nil {
+		<-s.block
+	}
+
+	switch r.Header.Get("Authorization") {
+	case "":
+		return nil, serviceauth.NoCredentials()
+	case "user-1":
+		return &actor.Actor{ID: 1, ExpiresAt: s.expires}, nil
+	case "user-2":
+		return &actor.Actor{ID: 2}, nil
+	case "ext-a", "ext-b":
+		return &actor.Actor{Kind: actor.KindService, StringID: r.Header.Get("Authorization")}, nil
+	case "broken":
+		return nil, serviceauth.Invalid(serviceauth.ReasonInternal, nil)
+	default:
+		return nil, serviceauth.Invalid(serviceauth.ReasonUnknownKey, nil)
+	}
+}
+
+func request(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if token != "" {
+		r.Header.Set("Authorization", token)
+	}
+
+	return r
+}
+
+func newCache(t *testing.T, inner *remote, cfg Config) *Authorizer {
+	t.Helper()
+
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = HeaderKey("Authorization")
+	}
+
+	a := New(inner, cfg)
+	_, err := a.Init(context.Background(), nil, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	return a
+}
+
+func TestAuthorizer_TTLAndExpiry(t *testing.T) {
+	now := time.Now()
+	inner := &remote{expires: now.Add(30 * time.Second)}
+	a := newCache(t, inner, Config{TTL: time.Minute, NegativeTTL: 5 * time.Second, Now: func() time.Time { return now }})
+
+	for range 3 {
+		act, err := a.AuthRest(request("user-1"))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), act.GetID())
+	}
+
+	assert.Equal(t, int32(1), inner.calls.Load())
+
+	// token expires before TTL
+	now = now.Add(31 * time.Second)
+	_, err := a.AuthRest(request("user-1"))
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), inner.calls.Load())
+
+	// negative cache, internal failures and absent credentials are not cached
+	for range 2 {
+		_, err = a.AuthRest(request("stolen"))
+		require.ErrorIs(t, err, serviceauth.ErrInvalidCredentials)
+		_, err = a.AuthRest(request("broken"))
+		require.ErrorIs(t, err, serviceauth.ErrInvalidCredentials)
+		_, err = a.AuthRest(request(""))
+		require.ErrorIs(t, err, serviceauth.ErrNoCredentials)
+	}
+
+	assert.Equal(t, int32(2+1+2+2), inner.calls.Load())
+
+	now = now.Add(6 * time.Second)
+	_, err = a.AuthRest(request("stolen"))
+	require.Error(t, err)
+	assert.Equal(t, int32(8), inner.calls.Load())
+
+	assert.InDelta(t, 2, testutil.ToFloat64(a.requests.WithLabelValues(defaultName, resultHit)), 0)
+	assert.InDelta(t, 1, testutil.ToFloat64(a.requests.WithLabelValues(defaultName, resultNegativeHit)), 0)
+	assert.InDelta(t, 2, testutil.ToFloat64(a.evictions.WithLabelValues(defaultName, evictionExpired)), 0)
+}
+
+func TestAuthorizer_LRUAndInvalidation(t *testing.T) {
+	inner := &remote{}
+	a := newCache(t, inner, Config{Size: 1})
+
+	_, err := a.AuthRest(request("user-1"))
+	require.NoError(t, err)
+	_, err = a.AuthRest(request("user-2"))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, a.Len())
+	assert.InDelta(t, 1, testutil.ToFloat64(a.evictions.WithLabelValues(defaultName, evictionSize)), 0)
+
+	assert.Equal(t, 1, a.InvalidateActor(&actor.Actor{ID: 2}))
+	assert.Equal(t, 0, a.Len())
+
+	_, err = a.AuthRest(request("user-2"))
+	require.NoError(t, err)
+	assert.True(t, a.Invalidate("Authorization:user-2"))
+	assert.False(t, a.Invalidate("Authorization:user-2"))
+
+	_, err = a.AuthRest(request("user-2"))
+	require.NoError(t, err)
+	a.Purge()
+	assert.Equal(t, 0, a.Len())
+}
+
+func TestAuthorizer_InvalidateStringIDActors(t *testing.T) {
+	a := newCache(t, &remote{}, Config{})
+
+	for _, token := range []string{"ext-a", "ext-b", "user-1"} {
+		_, err := a.AuthRest(request(token))
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 0, a.InvalidateActor(&actor.Actor{StringID: "ext-a"}), "kind differs")
+	assert.Equal(t, 1, a.InvalidateActor(&actor.Actor{Kind: actor.KindService, StringID: "ext-a"}))
+	assert.Equal(t, 2, a.Len())
+}
+
+func TestAuthorizer_KeyFunc(t *testing.T) {
+	_, err := New(&remote{}, Config{}).Init(context.Background(), nil, prometheus.NewRegistry())
+	require.ErrorIs(t, err, errNoKeyFunc)
+
+	inner := &remote{}
+	a := newCache(t, inner, Config{KeyFunc: Keys(HeaderKey("Authorization"), HeaderKey("X-Api-Key"))})
+
+	// the actor of both credentials is not served for one of them
+	r := request("user-1")
+	r.Header.Set("X-Api-Key", "key")
+
+	_, err = a.AuthRest(r)
+	require.NoError(t, err)
+
+	_, err = a.AuthRest(request("user-1"))
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), inner.calls.Load())
+}
+
+type auditRecorder struct {
+	mu     sync.Mutex
+	events []serviceauth.AuditEvent
+}
+
+func (r *auditRecorder) Audit(event serviceauth.AuditEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+}
+
+func TestAuthorizer_Audit(t *testing.T) {
+	var rec auditRecorder
+
+	serviceauth.SetAuditor(&rec)
+	t.Cleanup(func() { serviceauth.SetAuditor(nil) })
+
+	a := newCache(t, &remote{}, Config{})
+
+	// misses are audited by the inner authorizer
+	_, err := a.AuthRest(request("user-1"))
+	require.NoError(t, err)
+	_, err = a.AuthRest(request("unknown"))
+	require.Error(t, err)
+	assert.Empty(t, rec.events)
+
+	hit := request("user-1")
+	hit.RemoteAddr = "10.0.0.1:1234"
+	hit.Header.Set("User-Agent", "client")
+
+	_, err = a.AuthRest(hit)
+	require.NoError(t, err)
+	_, err = a.AuthRest(request("unknown"))
+	require.Error(t, err)
+
+	require.Len(t, rec.events, 2)
+	assert.Equal(t, Name, rec.events[0].Authorizer)
+	assert.True(t, rec.events[0].Success)
+	assert.Equal(t, int64(1), rec.events[0].ActorID)
+	assert.Equal(t, "10.0.0.1", rec.events[0].ClientIP)
+	assert.Equal(t, "client", rec.events[0].UserAgent)
+	assert.False(t, rec.events[1].Success)
+	assert.Equal(t, serviceauth.ReasonUnknownKey, rec.events[1].Reason)
+}
+
+func TestAuthorizer_Singleflight(t *testing.T) {
+	var rec auditRecorder
+
+	serviceauth.SetAuditor(&rec)
+	t.Cleanup(func() { serviceauth.SetAuditor(nil) })
+
+	inner := &remote{block: make(chan struct{})}
+	a := newCache(t, inner, Config{})
+
+	var wg sync.WaitGroup
+
+	for range 10 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			act, err := a.AuthRest(request("user-1"))
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1), act.GetID())
+		}()
+	}
+
+	require.Eventually(t, func() bool { return inner.calls.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	close(inner.block)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), inner.calls.Load())
+
+	// every request but the one of the leader, which is audited by the inner authorizer
+	assert.Len(t, rec.events, 9)
+}
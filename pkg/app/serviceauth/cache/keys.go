@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HeaderKey uses all non-empty headers of names as credentials
+func HeaderKey(names ...string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		var b strings.Builder
+
+		for _, name := range names {
+			if v := strings.TrimSpace(r.Header.Get(name)); v != "" {
+				writeKey(&b, name+":"+v)
+			}
+		}
+
+		return b.String(), b.Len() > 0
+	}
+}
+
+// Keys combines credentials found by all funcs, e.g. of every entry of a
+// chain.Authorizer: a request is cached under all credentials it carries, so
+// a cached actor is never served for a subset of them
+func Keys(funcs ...KeyFunc) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		var b strings.Builder
+
+		for _, f := range funcs {
+			if v, ok := f(r); ok {
+				writeKey(&b, v)
+			}
+		}
+
+		return b.String(), b.Len() > 0
+	}
+}
+
+// writeKey appends a part on a new line, header and cookie values can't contain newlines
+func writeKey(b *strings.Builder, part string) {
+	if b.Len() > 0 {
+		b.WriteByte('\n')
+	}
+
+	b.WriteString(part)
+}
+
+// CookieKey uses the cookie value as credentials
+func CookieKey(name string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		cookie, err := r.Cookie(name)
+		if err != nil || cookie.Value == "" {
+			return "", false
+		}
+
+		return "cookie:" + cookie.Value, true
+	}
+}
+
+// ClientCertKey uses the raw TLS client certificate as credentials
+func ClientCertKey(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return "", false
+	}
+
+	return "cert:" + string(r.TLS.PeerCertificates[0].Raw), true
+}
@@ -0,0 +1,194 @@
+// Package chain implements ds.Authorizer trying several authorizers in order.
+//
+// An authorizer failing with serviceauth.ErrNoCredentials passes the request
+// to the next one. Any other failure rejects the request: presented but
+// invalid credentials are never retried with another authorizer.
+//
+// The name of the authorizer that accepted the request is counted in the
+// auth_chain_selected_total metric and set on a copy of actors implementing
+// WithAuthMethod (actor.Actor does), so reqctx.SetActor stores it in the
+// request context, see reqctx.GetAuthMethod. Actors returned by entries are
+// never modified, they may be shared, e.g. by a cache.
+package chain
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-faster/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+)
+
+// Name of the authorizer in metrics
+const Name = "chain"
+
+// selectedNone is the metric label of requests without credentials for any authorizer
+const selectedNone = "none"
+
+var (
+	errEmptyChain    = errors.New("no authorizers in chain")
+	errEmptyName     = errors.New("authorizer name is empty")
+	errDuplicateName = errors.New("duplicate authorizer name")
+	errNilActor      = errors.New("authorizer returned nil actor")
+)
+
+// Entry is a named authorizer of the chain. The name is used in metrics and
+// reqctx, so it should be short and stable, e.g. jwtauth.Name.
+type Entry struct {
+	Name       string
+	Authorizer ds.Authorizer
+}
+
+// authMethodCopier is implemented by actors recording the authorizer
+type authMethodCopier interface {
+	WithAuthMethod(method string) ds.Actor
+}
+
+// Authorizer tries entries in order
+type Authorizer struct {
+	entries  []Entry
+	selected *prometheus.CounterVec
+}
+
+var (
+	_ ds.Authorizer         = (*Authorizer)(nil)
+	_ ds.GRPCAuthorizer     = (*Authorizer)(nil)
+	_ ds.TelegramAuthorizer = (*Authorizer)(nil)
+)
+
+// New creates the chain, entries are validated in Init
+func New(entries ...Entry) *Authorizer {
+	return &Authorizer{
+		entries: entries,
+		selected: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Subsystem: "auth",
+				Name:      "chain_selected_total",
+				Help:      "Total number of requests accepted by chain authorizers by authorizer name",
+			},
+			[]string{"authorizer"},
+		),
+	}
+}
+
+// Init initializes every authorizer of the chain with the same drivers and registry
+func (a *Authorizer) Init(ctx context.Context, drvs []ds.Runnable, m *prometheus.Registry) (ds.Authorizer, error) {
+	if len(a.entries) == 0 {
+		return nil, errEmptyChain
+	}
+
+	names := make(map[string]struct{}, len(a.entries))
+
+	for i, e := range a.entries {
+		if e.Name == "" {
+			return nil, errors.Wrapf(errEmptyName, "entry %d", i)
+		}
+
+		if _, ok := names[e.Name]; ok {
+			return nil, errors.Wrapf(errDuplicateName, "%q", e.Name)
+		}
+
+		names[e.Name] = struct{}{}
+
+		inner, err := e.Authorizer.Init(ctx, drvs, m)
+		if err != nil {
+			return nil, errors.Wrapf(err, "init authorizer %q", e.Name)
+		}
+
+		a.entries[i].Authorizer = inner
+	}
+
+	selected, err := serviceauth.Register(m, a.selected)
+	if err != nil {
+		return nil, err
+	}
+
+	a.selected = selected
+
+	return a, nil
+}
+
+// AuthRest returns the actor of the first authorizer finding credentials in
+// the request. If none does, the error is serviceauth.ErrNoCredentials, so
+// chains can be nested.
+func (a *Authorizer) AuthRest(r *http.Request) (ds.Actor, error) {
+	return a.authenticate(r.Context(), r, func(ctx context.Context, inner ds.Authorizer) (ds.Actor, error) {
+		return inner.AuthRest(r.WithContext(ctx))
+	})
+}
+
+// AuthGRPC is AuthRest for gRPC calls, see serviceauth.AuthGRPC
+func (a *Authorizer) AuthGRPC(ctx context.Context, md map[string][]string) (ds.Actor, error) {
+	return a.authenticate(serviceauth.WithTransport(ctx, serviceauth.TransportGRPC), nil, func(ctx context.Context, inner ds.Authorizer) (ds.Actor, error) {
+		return serviceauth.AuthGRPC(ctx, inner, md)
+	})
+}
+
+// AuthTelegram is AuthRest for Telegram updates, see serviceauth.AuthTelegram
+func (a *Authorizer) AuthTelegram(ctx context.Context, update ds.TelegramUpdate) (ds.Actor, error) {
+	return a.authenticate(serviceauth.WithTransport(ctx, serviceauth.TransportTelegram), nil, func(ctx context.Context, inner ds.Authorizer) (ds.Actor, error) {
+		return serviceauth.AuthTelegram(ctx, inner, update)
+	})
+}
+
+// authenticate tries auth with every entry, r is nil for gRPC calls and
+// Telegram updates. Entries get ctx marked by serviceauth.WithinChain.
+func (a *Authorizer) authenticate(ctx context.Context, r *http.Request, auth func(ctx context.Context, inner ds.Authorizer) (ds.Actor, error)) (ds.Actor, error) {
+	innerCtx := serviceauth.WithinChain(ctx)
+
+	for _, e := range a.entries {
+		act, err := auth(innerCtx, e.Authorizer)
+		if errors.Is(err, serviceauth.ErrNoCredentials) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if act == nil {
+			return nil, serviceauth.Invalid(serviceauth.ReasonInternal, errors.Wrapf(errNilActor, "%q", e.Name))
+		}
+
+		if c, ok := act.(authMethodCopier); ok {
+			act = c.WithAuthMethod(e.Name)
+		}
+
+		a.selected.WithLabelValues(e.Name).Inc()
+
+		return act, nil
+	}
+
+	a.selected.WithLabelValues(selectedNone).Inc()
+	serviceauth.AuditNoCredentials(ctx, r, Name)
+
+	return nil, serviceauth.NoCredentials()
+}
+
+// CheckCSRF is delegated to the authorizer AuthRest selects for the request,
+// i.e. the first one having credentials in it. Authorizers which don't
+// implement serviceauth.CredentialsDetector are assumed to have them, so they
+// should be placed last.
+func (a *Authorizer) CheckCSRF(r *http.Request) (bool, error) {
+	for _, e := range a.entries {
+		if serviceauth.HasCredentials(e.Authorizer, r) {
+			return e.Authorizer.CheckCSRF(r)
+		}
+	}
+
+	return true, nil
+}
+
+// HasCredentials reports whether any authorizer of the chain has credentials in the request
+func (a *Authorizer) HasCredentials(r *http.Request) bool {
+	for _, e := range a.entries {
+		if serviceauth.HasCredentials(e.Authorizer, r) {
+			return true
+		}
+	}
+
+	return false
+}
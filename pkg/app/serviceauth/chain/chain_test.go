@@ -0,0 +1,266 @@
+package chain
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app"
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth/apikey"
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth/csrf"
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth/jwtauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth/tgauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
+)
+
+var jwtSecret = []byte("0123456789abcdef0123456789abcdef")
+
+type initRecorder struct {
+	ds.Authorizer
+	drvs []ds.Runnable
+	m    *prometheus.Registry
+}
+
+func (r *initRecorder) Init(ctx context.Context, drvs []ds.Runnable, m *prometheus.Registry) (ds.Authorizer, error) {
+	r.drvs, r.m = drvs, m
+
+	inner, err := r.Authorizer.Init(ctx, drvs, m)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Authorizer = inner
+
+	return r, nil
+}
+
+func (r *initRecorder) HasCredentials(req *http.Request) bool {
+	return serviceauth.HasCredentials(r.Authorizer, req)
+}
+
+func newChain(t *testing.T) (*Authorizer, *prometheus.Registry, *initRecorder) {
+	t.Helper()
+
+	protector, err := csrf.New(csrf.Config{Mode: csrf.ModeDoubleSubmit, Secret: []byte("csrf-secret")})
+	require.NoError(t, err)
+
+	recorder := &initRecorder{Authorizer: jwtauth.New(jwtauth.Config{HMACSecret: jwtSecret})}
+	keys := csrf.Wrap(apikey.New(apikey.Config{Keys: []apikey.Key{{Name: "crm", Key: "secret-key", ActorID: 7}}}), protector)
+
+	a := New(Entry{Name: jwtauth.Name, Authorizer: recorder}, Entry{Name: apikey.Name, Authorizer: keys})
+
+	m := prometheus.NewRegistry()
+	_, err = a.Init(context.Background(), []ds.Runnable{nil}, m)
+	require.NoError(t, err)
+
+	return a, m, recorder
+}
+
+func TestAuthorizer(t *testing.T) {
+	a, m, recorder := newChain(t)
+
+	assert.Same(t, m, recorder.m)
+	assert.Len(t, recorder.drvs, 1)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "42", "exp": time.Now().Add(time.Hour).Unix()}).SignedString(jwtSecret)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	r.Header.Set("X-Api-Key", "secret-key")
+
+	act, err := a.AuthRest(r)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), act.GetID())
+
+	ctx, err := reqctx.SetActor(context.Background(), act)
+	require.NoError(t, err)
+	assert.Equal(t, jwtauth.Name, reqctx.GetAuthMethod(ctx))
+
+	// the same chain serves gRPC metadata, Telegram updates carry no credentials for it
+	act, err = a.AuthGRPC(context.Background(), map[string][]string{"x-api-key": {"secret-key"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), act.GetID())
+
+	_, err = a.AuthTelegram(context.Background(), ds.TelegramUpdate{UserID: 1})
+	require.ErrorIs(t, err, serviceauth.ErrNoCredentials)
+
+	// bearer tokens are not subject to CSRF even if the API key authorizer checks it
+	ok, err := a.CheckCSRF(r)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// absent token: fall through to the API key
+	r.Header.Del("Authorization")
+
+	act, err = a.AuthRest(r)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), act.GetID())
+
+	ok, _ = a.CheckCSRF(r)
+	assert.False(t, ok)
+
+	// invalid token rejects the request even though the API key is valid
+	r.Header.Set("Authorization", "Bearer garbage")
+
+	_, err = a.AuthRest(r)
+	require.ErrorIs(t, err, serviceauth.ErrInvalidCredentials)
+
+	// nothing at all
+	_, err = a.AuthRest(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.ErrorIs(t, err, serviceauth.ErrNoCredentials)
+
+	assert.InDelta(t, 1, testutil.ToFloat64(a.selected.WithLabelValues(jwtauth.Name)), 0)
+	assert.InDelta(t, 2, testutil.ToFloat64(a.selected.WithLabelValues(apikey.Name)), 0)
+	assert.InDelta(t, 2, testutil.ToFloat64(a.selected.WithLabelValues(selectedNone)), 0)
+}
+
+// tgLogin returns the Authorization header of the Telegram Login Widget
+func tgLogin(token string, fields url.Values) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, k+"="+fields.Get(k))
+	}
+
+	secret := sha256.Sum256([]byte(token))
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(strings.Join(lines, "\n")))
+	fields.Set("hash", hex.EncodeToString(mac.Sum(nil)))
+
+	return tgauth.SchemeLogin + " " + fields.Encode()
+}
+
+func TestAuthorizer_SharedAuthorizationHeader(t *testing.T) {
+	telegram, err := tgauth.New(tgauth.Config{Bots: []tgauth.Bot{{Name: "shop", Token: "111:shop-token"}}})
+	require.NoError(t, err)
+
+	a := New(
+		Entry{Name: jwtauth.Name, Authorizer: jwtauth.New(jwtauth.Config{HMACSecret: jwtSecret})},
+		Entry{Name: tgauth.Name, Authorizer: telegram},
+	)
+	_, err = a.Init(context.Background(), nil, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	// the Telegram scheme is not a bearer token, the chain falls through
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", tgLogin("111:shop-token", url.Values{
+		"id":        {"42"},
+		"auth_date": {strconv.FormatInt(time.Now().Unix(), 10)},
+	}))
+
+	act, err := a.AuthRest(r)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), act.GetID())
+
+	ctx, err := reqctx.SetActor(context.Background(), act)
+	require.NoError(t, err)
+	assert.Equal(t, tgauth.Name, reqctx.GetAuthMethod(ctx))
+
+	// schemes of neither authorizer are absent credentials
+	r.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+
+	_, err = a.AuthRest(r)
+	require.ErrorIs(t, err, serviceauth.ErrNoCredentials)
+}
+
+func TestAuthorizer_InitValidation(t *testing.T) {
+	_, err := New().Init(context.Background(), nil, nil)
+	require.ErrorIs(t, err, errEmptyChain)
+
+	dup := New(
+		Entry{Name: "jwt", Authorizer: jwtauth.New(jwtauth.Config{HMACSecret: jwtSecret})},
+		Entry{Name: "jwt", Authorizer: jwtauth.New(jwtauth.Config{HMACSecret: jwtSecret})},
+	)
+	_, err = dup.Init(context.Background(), nil, nil)
+	require.ErrorIs(t, err, errDuplicateName)
+
+	_, err = New(Entry{Name: "jwt", Authorizer: jwtauth.New(jwtauth.Config{})}).Init(context.Background(), nil, nil)
+	require.Error(t, err)
+}
+
+type auditRecorder []serviceauth.AuditEvent
+
+func (r *auditRecorder) Audit(event serviceauth.AuditEvent) {
+	*r = append(*r, event)
+}
+
+func TestAuthorizer_Audit(t *testing.T) {
+	a, _, _ := newChain(t)
+
+	var events auditRecorder
+
+	serviceauth.SetAuditor(&events)
+	t.Cleanup(func() { serviceauth.SetAuditor(nil) })
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Api-Key", "secret-key")
+
+	_, err := a.AuthRest(r)
+	require.NoError(t, err)
+
+	_, err = a.AuthGRPC(context.Background(), map[string][]string{})
+	require.ErrorIs(t, err, serviceauth.ErrNoCredentials)
+
+	// authorizers without credentials are skipped, the chain reports requests nobody has credentials for
+	require.Len(t, events, 2)
+	assert.Equal(t, apikey.Name, events[0].Authorizer)
+	assert.True(t, events[0].Success)
+	assert.Equal(t, Name, events[1].Authorizer)
+	assert.Equal(t, serviceauth.TransportGRPC, events[1].Transport)
+	assert.Equal(t, serviceauth.ReasonMissing, events[1].Reason)
+}
+
+type sharedActorAuthorizer struct {
+	app.UnimplementedAuthorizer
+	act *actor.ServiceActor
+}
+
+func (s *sharedActorAuthorizer) Init(context.Context, []ds.Runnable, *prometheus.Registry) (ds.Authorizer, error) {
+	return s, nil
+}
+
+func (s *sharedActorAuthorizer) AuthRest(*http.Request) (ds.Actor, error) {
+	return s.act, nil
+}
+
+func TestAuthorizer_DoesNotModifyActors(t *testing.T) {
+	shared := &actor.ServiceActor{Actor: actor.Actor{ID: 9}, Service: "billing"}
+
+	a := New(Entry{Name: "static", Authorizer: &sharedActorAuthorizer{act: shared}})
+	_, err := a.Init(context.Background(), nil, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	act, err := a.AuthRest(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+
+	copied, ok := act.(*actor.ServiceActor)
+	require.True(t, ok, "the copy keeps the actor type")
+	assert.Equal(t, "static", copied.GetAuthMethod())
+	assert.Equal(t, "billing", copied.Service)
+	assert.Empty(t, shared.AuthMethod)
+}
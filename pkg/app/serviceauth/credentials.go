@@ -0,0 +1,25 @@
+package serviceauth
+
+import (
+	"net/http"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+)
+
+// CredentialsDetector is implemented by authorizers which can tell whether a
+// request carries their credentials without validating them. HasCredentials
+// must return false exactly when AuthRest fails with ErrNoCredentials.
+type CredentialsDetector interface {
+	HasCredentials(r *http.Request) bool
+}
+
+// HasCredentials reports whether r carries credentials for a. Authorizers
+// which don't implement CredentialsDetector are assumed to find them.
+func HasCredentials(a ds.Authorizer, r *http.Request) bool {
+	d, ok := a.(CredentialsDetector)
+	if !ok {
+		return true
+	}
+
+	return d.HasCredentials(r)
+}
@@ -0,0 +1,115 @@
+package csrf
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-faster/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+)
+
+// Rejection reasons in metrics
+const (
+	reasonOrigin  = "origin"
+	reasonMissing = "missing"
+	reasonInvalid = "invalid"
+	reasonExpired = "expired"
+	reasonCookie  = "cookie"
+	reasonSession = "session"
+)
+
+// Authorizer adds CSRF protection to another authorizer: authentication is
+// delegated, CheckCSRF is done by the Protector
+type Authorizer struct {
+	ds.Authorizer
+	protector  *Protector
+	rejections *prometheus.CounterVec
+}
+
+var _ ds.Authorizer = (*Authorizer)(nil)
+
+// Wrap returns inner authorizer with CSRF checks of p
+func Wrap(inner ds.Authorizer, p *Protector) *Authorizer {
+	return &Authorizer{
+		Authorizer: inner,
+		protector:  p,
+		rejections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Subsystem: "auth",
+				Name:      "csrf_rejections_total",
+				Help:      "Total number of requests rejected by CSRF protection by reason",
+			},
+			[]string{"reason"},
+		),
+	}
+}
+
+// Init initializes the inner authorizer and registers CSRF metrics
+func (a *Authorizer) Init(ctx context.Context, drvs []ds.Runnable, m *prometheus.Registry) (ds.Authorizer, error) {
+	inner, err := a.Authorizer.Init(ctx, drvs, m)
+	if err != nil {
+		return nil, err
+	}
+
+	a.Authorizer = inner
+
+	if a.rejections, err = serviceauth.Register(m, a.rejections); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// CheckCSRF validates the request with the Protector. Tokens are bound to
+// the session found by Config.SessionFunc, by default the actor set by
+// reqctx.SetActor. The request is never authenticated here: a second AuthRest
+// would be counted and audited twice and would consume one-time credentials
+// (HMAC nonces). Without a session the check fails with ErrNoSession.
+func (a *Authorizer) CheckCSRF(r *http.Request) (bool, error) {
+	ok, err := a.protector.Check(r)
+	if !ok {
+		a.rejections.WithLabelValues(rejectionReason(err)).Inc()
+	}
+
+	return ok, err
+}
+
+// AuthGRPC delegates to the inner authorizer, gRPC calls are not subject to CSRF
+func (a *Authorizer) AuthGRPC(ctx context.Context, md map[string][]string) (ds.Actor, error) {
+	return serviceauth.AuthGRPC(ctx, a.Authorizer, md)
+}
+
+// AuthTelegram delegates to the inner authorizer
+func (a *Authorizer) AuthTelegram(ctx context.Context, update ds.TelegramUpdate) (ds.Actor, error) {
+	return serviceauth.AuthTelegram(ctx, a.Authorizer, update)
+}
+
+// HasCredentials delegates to the inner authorizer
+func (a *Authorizer) HasCredentials(r *http.Request) bool {
+	return serviceauth.HasCredentials(a.Authorizer, r)
+}
+
+// Protector returns the protector, e.g. to issue tokens in handlers
+func (a *Authorizer) Protector() *Protector {
+	return a.protector
+}
+
+func rejectionReason(err error) string {
+	switch {
+	case errors.Is(err, ErrOriginMismatch):
+		return reasonOrigin
+	case errors.Is(err, ErrTokenMissing):
+		return reasonMissing
+	case errors.Is(err, ErrTokenExpired):
+		return reasonExpired
+	case errors.Is(err, ErrCookieMismatch):
+		return reasonCookie
+	case errors.Is(err, ErrNoSession):
+		return reasonSession
+	default:
+		return reasonInvalid
+	}
+}
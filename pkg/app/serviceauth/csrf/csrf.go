@@ -0,0 +1,350 @@
+// Package csrf protects cookie authenticated REST endpoints from cross-site
+// request forgery.
+//
+// Tokens are HMAC signed and bound to the session (or actor) of the request,
+// so a token stolen from another session is useless. Requests without a
+// session are rejected: a token bound to no one would be valid for anyone. Two patterns are supported:
+//
+//   - synchronizer token: the page receives a token from Token and sends it
+//     back in the header or form field;
+//   - double-submit cookie: the token is also set in a cookie by SetCookie and
+//     the header value must be equal to the cookie value.
+//
+// For unsafe methods Origin (or Referer if Origin is absent) must match the
+// request host or one of trusted origins. Safe methods are exempt.
+//
+// The Protector is plugged into any ds.Authorizer with Wrap.
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-faster/errors"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
+)
+
+// Mode is a CSRF protection pattern
+type Mode int
+
+const (
+	// ModeSynchronizer validates the token from header or form field
+	ModeSynchronizer Mode = iota
+	// ModeDoubleSubmit additionally requires the token to equal the cookie value
+	ModeDoubleSubmit
+)
+
+const (
+	defaultCookieName = "csrf_token"
+	defaultHeaderName = "X-CSRF-Token"
+	defaultFormField  = "csrf_token"
+	defaultTTL        = 12 * time.Hour
+
+	timestampSize = 8
+	randomSize    = 16
+	tokenSize     = timestampSize + randomSize + sha256.Size
+)
+
+var (
+	ErrOriginMismatch = errors.New("csrf: origin mismatch")
+	ErrTokenMissing   = errors.New("csrf: token missing")
+	ErrTokenInvalid   = errors.New("csrf: token invalid")
+	ErrTokenExpired   = errors.New("csrf: token expired")
+	ErrCookieMismatch = errors.New("csrf: cookie does not match token")
+	ErrNoSession      = errors.New("csrf: no session to bind the token to")
+
+	errNoSecret = errors.New("csrf: secret is empty")
+)
+
+// Config of the CSRF protector
+type Config struct {
+	Mode Mode
+
+	// Secret signs tokens. PreviousSecrets are accepted for validation only, to rotate secrets.
+	Secret          []byte
+	PreviousSecrets [][]byte
+
+	CookieName string
+	HeaderName string
+	FormField  string
+
+	// CookieSecure and CookieSameSite are applied to the double-submit cookie
+	CookieSecure   bool
+	CookieSameSite http.SameSite
+
+	// TrustedOrigins are extra allowed origins, e.g. "https://app.example.com"
+	TrustedOrigins []string
+
+	// TTL of a token, 12 hours by default
+	TTL time.Duration
+
+	// SessionFunc returns the value tokens are bound to, "" if the request has
+	// no session. ActorSession by default, it requires CheckCSRF to run after
+	// reqctx.SetActor. It must not authenticate the request.
+	SessionFunc func(r *http.Request) string
+
+	// Now is used in tests
+	Now func() time.Time
+}
+
+// Protector issues and validates CSRF tokens
+type Protector struct {
+	cfg     Config
+	trusted map[string]struct{}
+}
+
+// New creates a protector
+func New(cfg Config) (*Protector, error) {
+	if len(cfg.Secret) == 0 {
+		return nil, errNoSecret
+	}
+
+	if cfg.CookieName == "" {
+		cfg.CookieName = defaultCookieName
+	}
+
+	if cfg.HeaderName == "" {
+		cfg.HeaderName = defaultHeaderName
+	}
+
+	if cfg.FormField == "" {
+		cfg.FormField = defaultFormField
+	}
+
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultTTL
+	}
+
+	if cfg.CookieSameSite == 0 {
+		cfg.CookieSameSite = http.SameSiteLaxMode
+	}
+
+	if cfg.SessionFunc == nil {
+		cfg.SessionFunc = ActorSession
+	}
+
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	trusted := make(map[string]struct{}, len(cfg.TrustedOrigins))
+	for _, origin := range cfg.TrustedOrigins {
+		trusted[strings.ToLower(strings.TrimSuffix(origin, "/"))] = struct{}{}
+	}
+
+	return &Protector{cfg: cfg, trusted: trusted}, nil
+}
+
+// ActorSession binds tokens to the actor stored in request context by reqctx.SetActor
+func ActorSession(r *http.Request) string {
+	act, err := reqctx.GetActor(r.Context())
+	if err != nil {
+		return ""
+	}
+
+	return SessionOf(act)
+}
+
+// SessionOf returns the kind and ID of act, "" for anonymous actors and actors without IDs
+func SessionOf(act ds.Actor) string {
+	if act == nil {
+		return ""
+	}
+
+	kind := actor.KindOf(act)
+	if kind == actor.KindAnonymous {
+		return ""
+	}
+
+	if act.GetID() != 0 {
+		return string(kind) + ":" + strconv.FormatInt(act.GetID(), 10)
+	}
+
+	if p, ok := act.(actor.StringIDProvider); ok && p.GetStringID() != "" {
+		return string(kind) + ":s:" + p.GetStringID()
+	}
+
+	return ""
+}
+
+// Token issues a new token bound to the session of r, ErrNoSession is
+// returned if r has none
+func (p *Protector) Token(r *http.Request) (string, error) {
+	session := p.cfg.SessionFunc(r)
+	if session == "" {
+		return "", ErrNoSession
+	}
+
+	raw := make([]byte, timestampSize+randomSize, tokenSize)
+	binary.BigEndian.PutUint64(raw, uint64(p.cfg.Now().Unix()))
+
+	if _, err := rand.Read(raw[timestampSize:]); err != nil {
+		return "", errors.Wrap(err, "csrf: generate token")
+	}
+
+	raw = append(raw, mac(p.cfg.Secret, session, raw)...)
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// SetCookie issues a token and sets it in the double-submit cookie.
+// The cookie is readable by scripts, which send it back in the header.
+func (p *Protector) SetCookie(w http.ResponseWriter, r *http.Request) (string, error) {
+	token, err := p.Token(r)
+	if err != nil {
+		return "", err
+	}
+
+	//nolint:gosec // the cookie must be readable by scripts for double-submit
+	http.SetCookie(w, &http.Cookie{
+		Name:     p.cfg.CookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(p.cfg.TTL.Seconds()),
+		Secure:   p.cfg.CookieSecure,
+		SameSite: p.cfg.CookieSameSite,
+	})
+
+	return token, nil
+}
+
+// Check validates r, it has the signature of ds.Authorizer.CheckCSRF
+func (p *Protector) Check(r *http.Request) (bool, error) {
+	return p.CheckWith(r, p.cfg.SessionFunc)
+}
+
+// CheckWith validates r against tokens bound to the value returned by session,
+// it is called only for unsafe methods
+func (p *Protector) CheckWith(r *http.Request, session func(r *http.Request) string) (bool, error) {
+	if isSafeMethod(r.Method) {
+		return true, nil
+	}
+
+	if err := p.checkOrigin(r); err != nil {
+		return false, err
+	}
+
+	token := r.Header.Get(p.cfg.HeaderName)
+	if token == "" {
+		token = r.PostFormValue(p.cfg.FormField)
+	}
+
+	if token == "" {
+		return false, ErrTokenMissing
+	}
+
+	if p.cfg.Mode == ModeDoubleSubmit {
+		cookie, err := r.Cookie(p.cfg.CookieName)
+		if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(token)) != 1 {
+			return false, ErrCookieMismatch
+		}
+	}
+
+	if err := p.validate(session(r), token); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (p *Protector) validate(session, token string) error {
+	if session == "" {
+		return ErrNoSession
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != tokenSize {
+		return ErrTokenInvalid
+	}
+
+	payload, sum := raw[:timestampSize+randomSize], raw[timestampSize+randomSize:]
+
+	valid := false
+
+	for _, secret := range append([][]byte{p.cfg.Secret}, p.cfg.PreviousSecrets...) {
+		if hmac.Equal(sum, mac(secret, session, payload)) {
+			valid = true
+			break
+		}
+	}
+
+	if !valid {
+		return ErrTokenInvalid
+	}
+
+	//nolint:gosec // timestamp is written by Token from a positive unix time
+	issued := time.Unix(int64(binary.BigEndian.Uint64(payload)), 0)
+	if p.cfg.Now().Sub(issued) > p.cfg.TTL {
+		return ErrTokenExpired
+	}
+
+	return nil
+}
+
+// checkOrigin compares Origin or Referer with the request host and trusted origins
+func (p *Protector) checkOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		referer := r.Header.Get("Referer")
+		if referer == "" {
+			// non-browser clients send neither, the token check still applies
+			if origin == "null" {
+				return ErrOriginMismatch
+			}
+
+			return nil
+		}
+
+		u, err := url.Parse(referer)
+		if err != nil {
+			return ErrOriginMismatch
+		}
+
+		origin = u.Scheme + "://" + u.Host
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return ErrOriginMismatch
+	}
+
+	if strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+
+	if _, ok := p.trusted[strings.ToLower(u.Scheme+"://"+u.Host)]; ok {
+		return nil
+	}
+
+	return ErrOriginMismatch
+}
+
+func mac(secret []byte, session string, payload []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write(payload)
+	h.Write([]byte{0})
+	h.Write([]byte(session))
+
+	return h.Sum(nil)
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
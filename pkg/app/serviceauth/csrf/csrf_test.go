@@ -0,0 +1,236 @@
+package csrf
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app"
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth/hmacauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
+)
+
+func withActor(t *testing.T, r *http.Request, id int64) *http.Request {
+	t.Helper()
+
+	ctx, err := reqctx.SetActor(r.Context(), &actor.Actor{ID: id})
+	require.NoError(t, err)
+
+	return r.WithContext(ctx)
+}
+
+func TestProtector_Synchronizer(t *testing.T) {
+	p, err := New(Config{Secret: []byte("secret"), TrustedOrigins: []string{"https://app.example.com"}})
+	require.NoError(t, err)
+
+	token, err := p.Token(withActor(t, httptest.NewRequest(http.MethodGet, "/form", nil), 1))
+	require.NoError(t, err)
+
+	post := func(id int64, token, origin string) (bool, error) {
+		r := httptest.NewRequest(http.MethodPost, "http://api.example.com/orders", strings.NewReader("csrf_token="+token))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+
+		return p.Check(withActor(t, r, id))
+	}
+
+	ok, err := post(1, token, "http://api.example.com")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = post(1, token, "https://app.example.com")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = post(1, token, "https://evil.example.com")
+	require.ErrorIs(t, err, ErrOriginMismatch)
+
+	_, err = post(2, token, "")
+	require.ErrorIs(t, err, ErrTokenInvalid, "token is bound to actor 1")
+
+	_, err = post(1, "", "")
+	require.ErrorIs(t, err, ErrTokenMissing)
+
+	ok, err = p.Check(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+	assert.True(t, ok, "safe methods are exempt")
+}
+
+func TestProtector_DoubleSubmitAndExpiry(t *testing.T) {
+	now := time.Now()
+
+	p, err := New(Config{Mode: ModeDoubleSubmit, Secret: []byte("secret"), TTL: time.Hour, Now: func() time.Time { return now }})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	token, err := p.SetCookie(w, withActor(t, httptest.NewRequest(http.MethodGet, "/", nil), 1))
+	require.NoError(t, err)
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	check := func(header string) error {
+		r := httptest.NewRequest(http.MethodDelete, "/orders/1", nil)
+		r.Header.Set("X-CSRF-Token", header)
+		r.AddCookie(cookies[0])
+
+		_, err := p.Check(withActor(t, r, 1))
+
+		return err
+	}
+
+	require.NoError(t, check(token))
+
+	other, err := p.Token(withActor(t, httptest.NewRequest(http.MethodGet, "/", nil), 1))
+	require.NoError(t, err)
+	require.ErrorIs(t, check(other), ErrCookieMismatch)
+
+	now = now.Add(2 * time.Hour)
+	require.ErrorIs(t, check(token), ErrTokenExpired)
+}
+
+func TestProtector_NoSession(t *testing.T) {
+	p, err := New(Config{Secret: []byte("secret")})
+	require.NoError(t, err)
+
+	_, err = p.Token(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.ErrorIs(t, err, ErrNoSession)
+
+	anonymous, err := reqctx.SetActor(context.Background(), actor.NewAnonymous())
+	require.NoError(t, err)
+
+	_, err = p.Token(httptest.NewRequest(http.MethodGet, "/", nil).WithContext(anonymous))
+	require.ErrorIs(t, err, ErrNoSession)
+
+	token, err := p.Token(withActor(t, httptest.NewRequest(http.MethodGet, "/", nil), 1))
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("X-CSRF-Token", token)
+
+	_, err = p.Check(r)
+	require.ErrorIs(t, err, ErrNoSession, "a request without session never passes")
+}
+
+func TestSessionOf(t *testing.T) {
+	assert.Equal(t, "user:1", SessionOf(&actor.Actor{ID: 1}))
+	assert.Equal(t, "service:s:crm", SessionOf(&actor.Actor{Kind: actor.KindService, StringID: "crm"}))
+	assert.NotEqual(t, SessionOf(&actor.Actor{StringID: "a"}), SessionOf(&actor.Actor{StringID: "b"}))
+	assert.Empty(t, SessionOf(actor.NewAnonymous()))
+	assert.Empty(t, SessionOf(nil))
+}
+
+func TestWrap_BindsToActor(t *testing.T) {
+	p, err := New(Config{Secret: []byte("secret")})
+	require.NoError(t, err)
+
+	victim := Wrap(&app.UnimplementedAuthorizer{}, p)
+
+	// token of the attacker issued in a handler with actor 1 in context
+	token, err := p.Token(withActor(t, httptest.NewRequest(http.MethodGet, "/", nil), 1))
+	require.NoError(t, err)
+
+	r := withActor(t, httptest.NewRequest(http.MethodPost, "/", nil), 2)
+	r.Header.Set("X-CSRF-Token", token)
+
+	ok, err := victim.CheckCSRF(r)
+	assert.False(t, ok)
+	require.ErrorIs(t, err, ErrTokenInvalid)
+
+	token, err = p.Token(withActor(t, httptest.NewRequest(http.MethodGet, "/", nil), 2))
+	require.NoError(t, err)
+
+	r.Header.Set("X-CSRF-Token", token)
+
+	ok, err = victim.CheckCSRF(r)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+type auditRecorder []serviceauth.AuditEvent
+
+func (r *auditRecorder) Audit(event serviceauth.AuditEvent) {
+	*r = append(*r, event)
+}
+
+func TestWrap_AuthenticatesOnce(t *testing.T) {
+	var events auditRecorder
+
+	serviceauth.SetAuditor(&events)
+	t.Cleanup(func() { serviceauth.SetAuditor(nil) })
+
+	p, err := New(Config{Secret: []byte("secret")})
+	require.NoError(t, err)
+
+	secret := []byte("internal-secret")
+	m := prometheus.NewRegistry()
+	a := Wrap(hmacauth.New(hmacauth.Config{Keys: []hmacauth.Key{{ID: "k1", Secret: secret, ActorID: 7}}}), p)
+	_, err = a.Init(context.Background(), nil, m)
+	require.NoError(t, err)
+
+	r, err := (&hmacauth.Signer{KeyID: "k1", Secret: secret}).Sign(httptest.NewRequest(http.MethodPost, "/", nil))
+	require.NoError(t, err)
+
+	token, err := p.Token(withActor(t, httptest.NewRequest(http.MethodGet, "/", nil), 7))
+	require.NoError(t, err)
+
+	r.Header.Set("X-CSRF-Token", token)
+
+	// the actor is not set yet: the check fails closed without using the nonce
+	ok, err := a.CheckCSRF(r)
+	assert.False(t, ok)
+	require.ErrorIs(t, err, ErrNoSession)
+
+	act, err := a.AuthRest(r)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), act.GetID())
+
+	ok, err = a.CheckCSRF(withActor(t, r, 7))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	assert.Len(t, events, 1)
+
+	families, err := m.Gather()
+	require.NoError(t, err)
+
+	var requests float64
+
+	for _, family := range families {
+		if family.GetName() == "auth_requests_total" {
+			for _, metric := range family.GetMetric() {
+				requests += metric.GetCounter().GetValue()
+			}
+		}
+	}
+
+	assert.InDelta(t, 1, requests, 0)
+}
+
+func TestWrap(t *testing.T) {
+	p, err := New(Config{Secret: []byte("secret")})
+	require.NoError(t, err)
+
+	a := Wrap(&app.UnimplementedAuthorizer{}, p)
+	_, err = a.Init(context.Background(), nil, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	ok, err := a.CheckCSRF(httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.False(t, ok)
+	require.ErrorIs(t, err, ErrTokenMissing)
+
+	_, err = a.AuthRest(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+}
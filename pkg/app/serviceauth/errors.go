@@ -0,0 +1,79 @@
+package serviceauth
+
+import (
+	"github.com/go-faster/errors"
+)
+
+// Failure reasons shared by authorizers. They are used as metric label values,
+// so the set of values must stay small and must never contain credentials.
+const (
+	ReasonMissing      = "missing"
+	ReasonMalformed    = "malformed"
+	ReasonSignature    = "signature"
+	ReasonExpired      = "expired"
+	ReasonNotYetValid  = "not_yet_valid"
+	ReasonIssuer       = "issuer"
+	ReasonAudience     = "audience"
+	ReasonUnknownKey   = "unknown_key"
+	ReasonUnknownActor = "unknown_actor"
+	ReasonInternal     = "internal"
+	// ReasonImpersonationDenied is a denied reqctx.Impersonate
+	ReasonImpersonationDenied = "impersonation_denied"
+)
+
+var (
+	// ErrNoCredentials means the request carries no credentials for the authorizer,
+	// another authorizer may still accept it
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials means credentials are present but rejected
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Error is an authentication failure with a reason suitable for metrics
+type Error struct {
+	// Kind is ErrNoCredentials or ErrInvalidCredentials
+	Kind   error
+	Reason string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Kind.Error() + ": " + e.Reason
+	}
+
+	return e.Kind.Error() + ": " + e.Reason + ": " + e.Err.Error()
+}
+
+// Is matches the kind of the failure
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NoCredentials returns an error for requests without credentials
+func NoCredentials() error {
+	return &Error{Kind: ErrNoCredentials, Reason: ReasonMissing}
+}
+
+// Invalid returns an error for rejected credentials
+func Invalid(reason string, err error) error {
+	return &Error{Kind: ErrInvalidCredentials, Reason: reason, Err: err}
+}
+
+// ReasonOf returns failure reason of err, ReasonInternal for foreign errors and "" for nil
+func ReasonOf(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var authErr *Error
+	if errors.As(err, &authErr) {
+		return authErr.Reason
+	}
+
+	return ReasonInternal
+}
@@ -0,0 +1,132 @@
+package serviceauth
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-faster/errors"
+)
+
+const defaultReloadInterval = 10 * time.Second
+
+// FileSource holds a value parsed from a file and reloads it when the file
+// changes on disk. The check is lazy: Get stats the file at most once per
+// interval, so no background goroutine is needed. If reload fails the
+// previous value is kept and the error is available via LastError.
+type FileSource[T any] struct {
+	path     string
+	interval time.Duration
+	parse    func([]byte) (T, error)
+
+	mu      sync.RWMutex
+	value   T
+	modTime time.Time
+	size    int64
+	checked time.Time
+	lastErr error
+}
+
+// NewFileSource loads path and returns a source reloading it. Zero interval means 10 seconds.
+func NewFileSource[T any](path string, interval time.Duration, parse func([]byte) (T, error)) (*FileSource[T], error) {
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+
+	s := &FileSource[T]{
+		path:     path,
+		interval: interval,
+		parse:    parse,
+	}
+
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Get returns the current value, reloading the file if it has changed
+func (s *FileSource[T]) Get() T {
+	s.mu.RLock()
+	value, due := s.value, time.Since(s.checked) >= s.interval
+	s.mu.RUnlock()
+
+	if !due {
+		return value
+	}
+
+	_ = s.reloadIfChanged()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.value
+}
+
+// Reload unconditionally reads and parses the file
+func (s *FileSource[T]) Reload() error {
+	st, err := os.Stat(s.path)
+	if err != nil {
+		return s.fail(errors.Wrapf(err, "stat %s", s.path))
+	}
+
+	return s.load(st)
+}
+
+// LastError returns the error of the last reload attempt
+func (s *FileSource[T]) LastError() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.lastErr
+}
+
+func (s *FileSource[T]) reloadIfChanged() error {
+	st, err := os.Stat(s.path)
+	if err != nil {
+		return s.fail(errors.Wrapf(err, "stat %s", s.path))
+	}
+
+	s.mu.Lock()
+	changed := !st.ModTime().Equal(s.modTime) || st.Size() != s.size
+	s.checked = time.Now()
+	s.mu.Unlock()
+
+	if !changed {
+		return nil
+	}
+
+	return s.load(st)
+}
+
+func (s *FileSource[T]) load(st os.FileInfo) error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return s.fail(errors.Wrapf(err, "read %s", s.path))
+	}
+
+	value, err := s.parse(data)
+	if err != nil {
+		return s.fail(errors.Wrapf(err, "parse %s", s.path))
+	}
+
+	s.mu.Lock()
+	s.value = value
+	s.modTime = st.ModTime()
+	s.size = st.Size()
+	s.checked = time.Now()
+	s.lastErr = nil
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *FileSource[T]) fail(err error) error {
+	s.mu.Lock()
+	s.lastErr = err
+	s.checked = time.Now()
+	s.mu.Unlock()
+
+	return err
+}
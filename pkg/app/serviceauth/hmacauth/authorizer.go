@@ -0,0 +1,214 @@
+package hmacauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+)
+
+// Name of the authorizer in metrics
+const Name = "hmac"
+
+// Failure reasons specific to signed requests
+const (
+	ReasonTimestamp = "timestamp"
+	ReasonReplay    = "replay"
+)
+
+const (
+	defaultReplayWindow = 5 * time.Minute
+	defaultNonceLimit   = 1 << 20
+)
+
+var (
+	errEmptySecret  = errors.New("secret is empty")
+	errInvalidActor = errors.New("actor_id must not be negative")
+)
+
+// Key is a signing key
+type Key struct {
+	ID     string `json:"id"`
+	Secret []byte `json:"secret"`
+	// ActorID of the service actor, it is identified by the key ID if zero
+	ActorID int64 `json:"actor_id"`
+}
+
+// Config of the HMAC authorizer
+type Config struct {
+	// Keys accepted by key ID
+	Keys []Key
+
+	// ReplayWindow is the maximum allowed difference between request timestamp
+	// and server time in both directions, 5 minutes by default
+	ReplayWindow time.Duration
+
+	// NonceLimit bounds the nonce cache, requests are rejected when it is full
+	NonceLimit int
+
+	// MaxBodySize limits the body read for hashing, 10MB by default
+	MaxBodySize int64
+
+	// Now is used in tests
+	Now func() time.Time
+}
+
+// Authorizer verifies HMAC signed requests
+type Authorizer struct {
+	cfg     Config
+	keys    atomic.Pointer[map[string]Key]
+	nonces  *nonceCache
+	metrics *serviceauth.Metrics
+}
+
+var _ ds.Authorizer = (*Authorizer)(nil)
+
+// New creates the authorizer
+func New(cfg Config) *Authorizer {
+	if cfg.ReplayWindow <= 0 {
+		cfg.ReplayWindow = defaultReplayWindow
+	}
+
+	if cfg.NonceLimit <= 0 {
+		cfg.NonceLimit = defaultNonceLimit
+	}
+
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = defaultMaxBodySize
+	}
+
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	return &Authorizer{
+		cfg: cfg,
+		// a nonce must be remembered while its timestamp is inside the window
+		nonces: newNonceCache(2*cfg.ReplayWindow, cfg.NonceLimit),
+	}
+}
+
+func (a *Authorizer) Init(_ context.Context, _ []ds.Runnable, m *prometheus.Registry) (ds.Authorizer, error) {
+	if err := a.SetKeys(a.cfg.Keys); err != nil {
+		return nil, err
+	}
+
+	metrics, err := serviceauth.NewMetrics(m)
+	if err != nil {
+		return nil, err
+	}
+
+	a.metrics = metrics
+
+	return a, nil
+}
+
+// SetKeys atomically replaces accepted keys
+func (a *Authorizer) SetKeys(keys []Key) error {
+	byID := make(map[string]Key, len(keys))
+
+	for _, k := range keys {
+		if len(k.Secret) == 0 {
+			return errors.Wrapf(errEmptySecret, "key %q", k.ID)
+		}
+
+		if k.ActorID < 0 {
+			return errors.Wrapf(errInvalidActor, "key %q", k.ID)
+		}
+
+		byID[k.ID] = k
+	}
+
+	a.keys.Store(&byID)
+
+	return nil
+}
+
+// AuthRest verifies the signature. The body is read for hashing and replaced
+// with an in-memory copy, so handlers can still read it.
+func (a *Authorizer) AuthRest(r *http.Request) (ds.Actor, error) {
+	act, err := a.authenticate(r)
+	a.metrics.ObserveRequest(r, Name, act, err)
+
+	return act, err
+}
+
+// CheckCSRF always succeeds: signatures are not attached by browsers automatically
+func (a *Authorizer) CheckCSRF(_ *http.Request) (bool, error) {
+	return true, nil
+}
+
+// HasCredentials reports whether the request carries a key ID or a signature
+func (a *Authorizer) HasCredentials(r *http.Request) bool {
+	return r.Header.Get(HeaderKeyID) != "" || r.Header.Get(HeaderSignature) != ""
+}
+
+func (a *Authorizer) authenticate(r *http.Request) (ds.Actor, error) {
+	keyID := r.Header.Get(HeaderKeyID)
+	signature := r.Header.Get(HeaderSignature)
+
+	if keyID == "" && signature == "" {
+		return nil, serviceauth.NoCredentials()
+	}
+
+	timestamp := r.Header.Get(HeaderTimestamp)
+	nonce := r.Header.Get(HeaderNonce)
+
+	if keyID == "" || signature == "" || timestamp == "" || nonce == "" {
+		return nil, serviceauth.Invalid(serviceauth.ReasonMalformed, nil)
+	}
+
+	var key Key
+
+	if keys := a.keys.Load(); keys != nil {
+		key = (*keys)[keyID]
+	}
+
+	if key.Secret == nil {
+		return nil, serviceauth.Invalid(serviceauth.ReasonUnknownKey, nil)
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, serviceauth.Invalid(serviceauth.ReasonMalformed, err)
+	}
+
+	now := a.cfg.Now()
+	if skew := now.Sub(time.Unix(ts, 0)).Abs(); skew > a.cfg.ReplayWindow {
+		return nil, serviceauth.Invalid(ReasonTimestamp, nil)
+	}
+
+	bodyHash, body, err := readBody(r.Body, a.cfg.MaxBodySize)
+	if err != nil {
+		return nil, serviceauth.Invalid(serviceauth.ReasonMalformed, err)
+	}
+
+	r.Body = body
+
+	expected := sign(key.Secret, canonicalRequest(r.Method, r.URL.EscapedPath(), r.URL.Query(), bodyHash, timestamp, nonce))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, serviceauth.Invalid(serviceauth.ReasonSignature, nil)
+	}
+
+	// nonce is registered only for valid signatures, so forged requests can't fill the cache
+	if !a.nonces.add(keyID+":"+nonce, now) {
+		return nil, serviceauth.Invalid(ReasonReplay, nil)
+	}
+
+	act := &actor.ServiceActor{Actor: actor.Actor{ID: key.ActorID, Kind: actor.KindService, Name: keyID}, Service: keyID}
+	if key.ActorID == 0 {
+		// identified by the key ID
+		act.StringID = keyID
+	}
+
+	return act, nil
+}
@@ -0,0 +1,148 @@
+package hmacauth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
+)
+
+var secret = []byte("internal-secret")
+
+func newAuthorizer(t *testing.T, now func() time.Time) *Authorizer {
+	t.Helper()
+
+	a := New(Config{
+		Keys: []Key{{ID: "k1", Secret: secret, ActorID: 77}},
+		Now:  now,
+	})
+	_, err := a.Init(context.Background(), nil, nil)
+	require.NoError(t, err)
+
+	return a
+}
+
+func TestSignerAndAuthorizer(t *testing.T) {
+	a := newAuthorizer(t, nil)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		act, err := a.AuthRest(r)
+		if err != nil {
+			http.Error(w, serviceauth.ReasonOf(err), http.StatusUnauthorized)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"id":1}`, string(body))
+		assert.Equal(t, int64(77), act.GetID())
+		assert.Equal(t, actor.KindService, actor.KindOf(act))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewSigner("k1", secret, nil)}
+
+	resp, err := client.Post(srv.URL+"/orders?b=2&a=1&a=0", "application/json", strings.NewReader(`{"id":1}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestAuthorizer_KeyWithoutActorID(t *testing.T) {
+	a := New(Config{Keys: []Key{{ID: "k1", Secret: secret}}})
+	_, err := a.Init(context.Background(), nil, nil)
+	require.NoError(t, err)
+
+	act, err := a.AuthRest(signedRequest(t, time.Now(), `{"id":1}`))
+	require.NoError(t, err)
+	assert.Equal(t, "k1", act.(*actor.ServiceActor).GetStringID())
+
+	_, err = reqctx.SetActor(context.Background(), act)
+	require.NoError(t, err)
+
+	require.ErrorIs(t, a.SetKeys([]Key{{ID: "k1", Secret: secret, ActorID: -1}}), errInvalidActor)
+}
+
+type trackingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestSigner_ClosesBody(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	original := &trackingBody{Reader: strings.NewReader("{}")}
+	req.Body = original
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("{}")), nil
+	}
+
+	signed, err := (&Signer{KeyID: "k1", Secret: secret}).Sign(req)
+	require.NoError(t, err)
+	assert.True(t, original.closed)
+
+	body, err := io.ReadAll(signed.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(body))
+}
+
+func signedRequest(t *testing.T, now time.Time, body string) *http.Request {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/orders?x=1", strings.NewReader(body))
+
+	signer := &Signer{KeyID: "k1", Secret: secret, Now: func() time.Time { return now }}
+
+	signed, err := signer.Sign(req)
+	require.NoError(t, err)
+
+	return signed
+}
+
+func TestAuthorizer_Rejects(t *testing.T) {
+	now := time.Now()
+	a := newAuthorizer(t, func() time.Time { return now })
+
+	_, err := a.AuthRest(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.ErrorIs(t, err, serviceauth.ErrNoCredentials)
+
+	req := signedRequest(t, now, "payload")
+	replay := req.Clone(context.Background())
+	replay.Body = io.NopCloser(strings.NewReader("payload"))
+
+	_, err = a.AuthRest(req)
+	require.NoError(t, err)
+
+	_, err = a.AuthRest(replay)
+	assert.Equal(t, ReasonReplay, serviceauth.ReasonOf(err))
+
+	tampered := signedRequest(t, now, "payload")
+	tampered.Body = io.NopCloser(strings.NewReader("other"))
+	_, err = a.AuthRest(tampered)
+	assert.Equal(t, serviceauth.ReasonSignature, serviceauth.ReasonOf(err))
+
+	_, err = a.AuthRest(signedRequest(t, now.Add(-time.Hour), "payload"))
+	assert.Equal(t, ReasonTimestamp, serviceauth.ReasonOf(err))
+
+	unknown := signedRequest(t, now, "payload")
+	unknown.Header.Set(HeaderKeyID, "k2")
+	_, err = a.AuthRest(unknown)
+	assert.Equal(t, serviceauth.ReasonUnknownKey, serviceauth.ReasonOf(err))
+}
+
+func TestSortedQuery(t *testing.T) {
+	assert.Equal(t, "a=0&a=1&b=2+3", sortedQuery(map[string][]string{"b": {"2 3"}, "a": {"1", "0"}}))
+}
@@ -0,0 +1,56 @@
+package hmacauth
+
+import (
+	"sync"
+	"time"
+)
+
+// nonceCache remembers nonces until they leave the replay window
+type nonceCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	maxSize   int
+	lastSweep time.Time
+	ttl       time.Duration
+}
+
+func newNonceCache(ttl time.Duration, maxSize int) *nonceCache {
+	return &nonceCache{
+		seen:    make(map[string]time.Time),
+		maxSize: maxSize,
+		ttl:     ttl,
+	}
+}
+
+// add registers nonce and returns false if it was seen before or the cache is full
+func (c *nonceCache) add(nonce string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastSweep) >= c.ttl || len(c.seen) >= c.maxSize {
+		c.sweep(now)
+	}
+
+	if expires, ok := c.seen[nonce]; ok && now.Before(expires) {
+		return false
+	}
+
+	// fail closed: a replay can't be ruled out without remembering the nonce
+	if len(c.seen) >= c.maxSize {
+		return false
+	}
+
+	c.seen[nonce] = now.Add(c.ttl)
+
+	return true
+}
+
+func (c *nonceCache) sweep(now time.Time) {
+	for nonce, expires := range c.seen {
+		if !now.Before(expires) {
+			delete(c.seen, nonce)
+		}
+	}
+
+	c.lastSweep = now
+}
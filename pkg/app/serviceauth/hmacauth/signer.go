@@ -0,0 +1,108 @@
+package hmacauth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-faster/errors"
+)
+
+const nonceSize = 16
+
+// Signer is an http.RoundTripper signing outgoing requests
+type Signer struct {
+	KeyID  string
+	Secret []byte
+
+	// Base is the underlying transport, http.DefaultTransport by default
+	Base http.RoundTripper
+
+	// MaxBodySize limits the body read for hashing, 10MB by default
+	MaxBodySize int64
+
+	// Now is used in tests
+	Now func() time.Time
+}
+
+var _ http.RoundTripper = (*Signer)(nil)
+
+// NewSigner creates a signer on top of base transport
+func NewSigner(keyID string, secret []byte, base http.RoundTripper) *Signer {
+	return &Signer{KeyID: keyID, Secret: secret, Base: base}
+}
+
+// RoundTrip signs a clone of req and sends it with the base transport
+func (s *Signer) RoundTrip(req *http.Request) (*http.Response, error) {
+	signed, err := s.Sign(req)
+	if err != nil {
+		// RoundTrip must always close the body
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+
+		return nil, err
+	}
+
+	base := s.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return base.RoundTrip(signed)
+}
+
+// Sign returns a signed clone of req. The body of req is consumed: it is read
+// into the clone or, if req.GetBody is set, replaced by a fresh copy and closed.
+func (s *Signer) Sign(req *http.Request) (*http.Request, error) {
+	limit := s.MaxBodySize
+	if limit <= 0 {
+		limit = defaultMaxBodySize
+	}
+
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+
+	signed := req.Clone(req.Context())
+
+	body := req.Body
+	if req.GetBody != nil {
+		var err error
+
+		if body, err = req.GetBody(); err != nil {
+			return nil, errors.Wrap(err, "get body")
+		}
+
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+	}
+
+	bodyHash, body, err := readBody(body, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	signed.Body = body
+
+	rawNonce := make([]byte, nonceSize)
+	if _, err = rand.Read(rawNonce); err != nil {
+		return nil, errors.Wrap(err, "generate nonce")
+	}
+
+	nonce := hex.EncodeToString(rawNonce)
+	timestamp := strconv.FormatInt(now().Unix(), 10)
+
+	signed.Header.Set(HeaderKeyID, s.KeyID)
+	signed.Header.Set(HeaderTimestamp, timestamp)
+	signed.Header.Set(HeaderNonce, nonce)
+	signed.Header.Set(HeaderSignature, sign(s.Secret, canonicalRequest(
+		signed.Method, signed.URL.EscapedPath(), signed.URL.Query(), bodyHash, timestamp, nonce,
+	)))
+
+	return signed, nil
+}
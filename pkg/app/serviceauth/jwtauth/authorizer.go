@@ -0,0 +1,327 @@
+// Package jwtauth implements ds.Authorizer for JWT bearer tokens.
+//
+// Supported algorithms are HS256, RS256 and ES256. HMAC secret may be passed
+// directly, public keys are read from a local JWKS file which is reloaded
+// when it changes on disk, so keys can be rotated without restart.
+package jwtauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+)
+
+// Name of the authorizer in metrics
+const Name = "jwt"
+
+const (
+	defaultHeader = "Authorization"
+	bearerPrefix  = "Bearer "
+)
+
+var (
+	defaultAlgorithms = []string{
+		jwt.SigningMethodHS256.Alg(),
+		jwt.SigningMethodRS256.Alg(),
+		jwt.SigningMethodES256.Alg(),
+	}
+
+	errNoKeys         = errors.New("neither HMAC secret nor JWKS file configured")
+	errKeyNotFound    = errors.New("signing key not found")
+	errSubjectMissing = errors.New("subject claim is missing")
+)
+
+// ActorFunc maps validated claims to an actor
+type ActorFunc func(claims jwt.MapClaims) (ds.Actor, error)
+
+// Config of the JWT authorizer
+type Config struct {
+	// Header with the token, "Authorization" by default. The Authorization header
+	// requires the Bearer scheme, other schemes are credentials of other authorizers.
+	Header string
+
+	// Algorithms accepted, HS256, RS256 and ES256 by default
+	Algorithms []string
+
+	// HMACSecret for HS256 tokens
+	HMACSecret []byte
+
+	// JWKSFile is a path to a JSON Web Key Set with RS256/ES256 (and optionally oct) keys
+	JWKSFile string
+	// JWKSReloadInterval is how often the file is checked for changes, 10 seconds by default
+	JWKSReloadInterval time.Duration
+
+	// Issuer and Audience are validated when not empty
+	Issuer   string
+	Audience string
+
+	// Leeway is the clock skew tolerance for exp, nbf and iat
+	Leeway time.Duration
+
+	// ActorFunc maps claims to an actor, by default "sub" is parsed as numeric actor ID
+	ActorFunc ActorFunc
+}
+
+// Authorizer validates JWT bearer tokens
+type Authorizer struct {
+	cfg     Config
+	parser  *jwt.Parser
+	keys    *serviceauth.FileSource[*KeySet]
+	metrics *serviceauth.Metrics
+}
+
+var _ ds.Authorizer = (*Authorizer)(nil)
+
+// New creates the authorizer, keys are loaded in Init
+func New(cfg Config) *Authorizer {
+	if cfg.Header == "" {
+		cfg.Header = defaultHeader
+	}
+
+	if len(cfg.Algorithms) == 0 {
+		cfg.Algorithms = defaultAlgorithms
+	}
+
+	if cfg.ActorFunc == nil {
+		cfg.ActorFunc = SubjectActor
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(cfg.Algorithms),
+		jwt.WithLeeway(cfg.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	return &Authorizer{
+		cfg:    cfg,
+		parser: jwt.NewParser(opts...),
+	}
+}
+
+func (a *Authorizer) Init(_ context.Context, _ []ds.Runnable, m *prometheus.Registry) (ds.Authorizer, error) {
+	if len(a.cfg.HMACSecret) == 0 && a.cfg.JWKSFile == "" {
+		return nil, errNoKeys
+	}
+
+	if a.cfg.JWKSFile != "" {
+		keys, err := serviceauth.NewFileSource(a.cfg.JWKSFile, a.cfg.JWKSReloadInterval, ParseJWKS)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't load jwks")
+		}
+
+		a.keys = keys
+	}
+
+	metrics, err := serviceauth.NewMetrics(m)
+	if err != nil {
+		return nil, err
+	}
+
+	a.metrics = metrics
+
+	return a, nil
+}
+
+func (a *Authorizer) AuthRest(r *http.Request) (ds.Actor, error) {
+	act, err := a.authenticate(a.token(r))
+	a.metrics.ObserveRequest(r, Name, act, err)
+
+	return act, err
+}
+
+// CheckCSRF always succeeds: bearer tokens are not attached by browsers automatically
+func (a *Authorizer) CheckCSRF(_ *http.Request) (bool, error) {
+	return true, nil
+}
+
+// HasCredentials reports whether the request carries a token
+func (a *Authorizer) HasCredentials(r *http.Request) bool {
+	return a.token(r) != ""
+}
+
+// Authenticate validates raw token and maps it to an actor
+func (a *Authorizer) Authenticate(token string) (ds.Actor, error) {
+	act, err := a.authenticate(token)
+	a.metrics.Observe(Name, act, err)
+
+	return act, err
+}
+
+func (a *Authorizer) authenticate(token string) (ds.Actor, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, serviceauth.NoCredentials()
+	}
+
+	claims := jwt.MapClaims{}
+
+	if _, err := a.parser.ParseWithClaims(token, claims, a.keyFunc); err != nil {
+		return nil, serviceauth.Invalid(failureReason(err), err)
+	}
+
+	act, err := a.cfg.ActorFunc(claims)
+	if err != nil {
+		return nil, serviceauth.Invalid(serviceauth.ReasonUnknownActor, err)
+	}
+
+	return act, nil
+}
+
+// token returns the token of r, "" if there is none. The value of a custom
+// header is the token itself.
+func (a *Authorizer) token(r *http.Request) string {
+	header := strings.TrimSpace(r.Header.Get(a.cfg.Header))
+	if !strings.EqualFold(a.cfg.Header, defaultHeader) {
+		return header
+	}
+
+	return bearerToken(header)
+}
+
+// bearerToken returns the token of the Bearer scheme, "" for other schemes
+func bearerToken(header string) string {
+	if len(header) < len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return ""
+	}
+
+	return strings.TrimSpace(header[len(bearerPrefix):])
+}
+
+func (a *Authorizer) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if len(a.cfg.HMACSecret) > 0 && kid == "" {
+			return a.cfg.HMACSecret, nil
+		}
+
+		return a.lookup(kid, isHMAC)
+	case *jwt.SigningMethodRSA:
+		return a.lookup(kid, isRSA)
+	case *jwt.SigningMethodECDSA:
+		return a.lookup(kid, isECDSA)
+	default:
+		return nil, errKeyNotFound
+	}
+}
+
+func (a *Authorizer) lookup(kid string, match func(any) bool) (any, error) {
+	if a.keys == nil {
+		return nil, errKeyNotFound
+	}
+
+	key, ok := a.keys.Get().lookup(kid, match)
+	if !ok {
+		return nil, errKeyNotFound
+	}
+
+	return key, nil
+}
+
+func isHMAC(key any) bool {
+	_, ok := key.([]byte)
+	return ok
+}
+
+func isRSA(key any) bool {
+	_, ok := key.(*rsa.PublicKey)
+	return ok
+}
+
+func isECDSA(key any) bool {
+	_, ok := key.(*ecdsa.PublicKey)
+	return ok
+}
+
+// failureReason maps jwt validation errors to metric reasons
+func failureReason(err error) string {
+	switch {
+	case errors.Is(err, errKeyNotFound):
+		return serviceauth.ReasonUnknownKey
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return serviceauth.ReasonExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return serviceauth.ReasonNotYetValid
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return serviceauth.ReasonIssuer
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return serviceauth.ReasonAudience
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return serviceauth.ReasonSignature
+	default:
+		return serviceauth.ReasonMalformed
+	}
+}
+
+// SubjectActor is the default ActorFunc, it parses "sub" claim as numeric actor ID.
+// Roles are taken from the "roles" claim, scopes from the space separated "scope"
+// claim. The actor expires together with the token.
+func SubjectActor(claims jwt.MapClaims) (ds.Actor, error) {
+	sub, err := claims.GetSubject()
+	if err != nil {
+		return nil, err
+	}
+
+	if sub == "" {
+		return nil, errSubjectMissing
+	}
+
+	id, err := strconv.ParseInt(sub, 10, 64)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse subject")
+	}
+
+	act := &actor.Actor{ID: id, Roles: stringsClaim(claims, "roles")}
+
+	if scope, ok := claims["scope"].(string); ok {
+		act.Scopes = strings.Fields(scope)
+	}
+
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		act.ExpiresAt = exp.Time
+	}
+
+	return act, nil
+}
+
+// stringsClaim returns a claim holding a list of strings, a single string is a list of one
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		list := make([]string, 0, len(v))
+
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+
+		return list
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,222 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+)
+
+var hmacSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func signHS256(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(hmacSecret)
+	require.NoError(t, err)
+
+	return token
+}
+
+func initAuthorizer(t *testing.T, cfg Config) *Authorizer {
+	t.Helper()
+
+	a := New(cfg)
+	_, err := a.Init(context.Background(), nil, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	return a
+}
+
+func authRequest(a *Authorizer, token string) error {
+	r := httptest.NewRequest("GET", "/", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	_, err := a.AuthRest(r)
+
+	return err
+}
+
+func TestAuthorizer_HS256(t *testing.T) {
+	a := initAuthorizer(t, Config{
+		HMACSecret: hmacSecret,
+		Issuer:     "auth",
+		Audience:   "orders",
+		Leeway:     time.Minute,
+	})
+
+	now := time.Now()
+	valid := jwt.MapClaims{
+		"sub": "42", "iss": "auth", "aud": "orders", "exp": now.Add(time.Hour).Unix(),
+		"roles": []string{"manager"}, "scope": "orders:read orders:write",
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+signHS256(t, valid))
+
+	act, err := a.AuthRest(r)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), act.GetID())
+	assert.Equal(t, []string{"manager"}, act.(*actor.Actor).Roles)
+	assert.Equal(t, []string{"orders:read", "orders:write"}, act.(*actor.Actor).Scopes)
+
+	// other schemes are credentials of other authorizers
+	for _, header := range []string{"Basic dXNlcjpwYXNz", "tma query_id=1", signHS256(t, valid)} {
+		r = httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", header)
+
+		assert.False(t, a.HasCredentials(r), header)
+
+		_, err = a.AuthRest(r)
+		require.ErrorIs(t, err, serviceauth.ErrNoCredentials, header)
+	}
+
+	// within leeway
+	skewed := jwt.MapClaims{"sub": "42", "iss": "auth", "aud": "orders", "exp": now.Add(-30 * time.Second).Unix()}
+	require.NoError(t, authRequest(a, signHS256(t, skewed)))
+
+	tests := []struct {
+		name   string
+		token  string
+		reason string
+	}{
+		{name: "missing", reason: serviceauth.ReasonMissing},
+		{name: "garbage", token: "not.a.jwt", reason: serviceauth.ReasonMalformed},
+		{
+			name:   "expired",
+			token:  signHS256(t, jwt.MapClaims{"sub": "42", "iss": "auth", "aud": "orders", "exp": now.Add(-time.Hour).Unix()}),
+			reason: serviceauth.ReasonExpired,
+		},
+		{
+			name:   "issuer",
+			token:  signHS256(t, jwt.MapClaims{"sub": "42", "iss": "evil", "aud": "orders", "exp": now.Add(time.Hour).Unix()}),
+			reason: serviceauth.ReasonIssuer,
+		},
+		{
+			name:   "audience",
+			token:  signHS256(t, jwt.MapClaims{"sub": "42", "iss": "auth", "aud": "billing", "exp": now.Add(time.Hour).Unix()}),
+			reason: serviceauth.ReasonAudience,
+		},
+		{
+			name:   "no exp",
+			token:  signHS256(t, jwt.MapClaims{"sub": "42", "iss": "auth", "aud": "orders"}),
+			reason: serviceauth.ReasonMalformed,
+		},
+		{
+			name:   "bad subject",
+			token:  signHS256(t, jwt.MapClaims{"sub": "bob", "iss": "auth", "aud": "orders", "exp": now.Add(time.Hour).Unix()}),
+			reason: serviceauth.ReasonUnknownActor,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authRequest(a, tt.token)
+			require.Error(t, err)
+			assert.Equal(t, tt.reason, serviceauth.ReasonOf(err))
+		})
+	}
+}
+
+func writeJWKS(t *testing.T, path string, keys map[string]*ecdsa.PrivateKey) {
+	t.Helper()
+
+	jwks := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+
+	for kid, key := range keys {
+		jwks.Keys = append(jwks.Keys, jsonWebKey{
+			Kty: ktyEC,
+			Kid: kid,
+			Crv: curveP256,
+			X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		})
+	}
+
+	data, err := json.Marshal(jwks)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func TestAuthorizer_ES256WithJWKSRotation(t *testing.T) {
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, map[string]*ecdsa.PrivateKey{"old": oldKey})
+
+	a := initAuthorizer(t, Config{JWKSFile: path, JWKSReloadInterval: time.Nanosecond})
+
+	sign := func(kid string, key *ecdsa.PrivateKey) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"sub": "7", "exp": time.Now().Add(time.Hour).Unix()})
+		token.Header["kid"] = kid
+
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+
+		return signed
+	}
+
+	require.NoError(t, authRequest(a, sign("old", oldKey)))
+
+	err = authRequest(a, sign("new", newKey))
+	assert.Equal(t, serviceauth.ReasonUnknownKey, serviceauth.ReasonOf(err))
+
+	writeJWKS(t, path, map[string]*ecdsa.PrivateKey{"old": oldKey, "new": newKey})
+	require.NoError(t, authRequest(a, sign("new", newKey)))
+
+	// key substitution under known kid is rejected
+	err = authRequest(a, sign("old", newKey))
+	assert.Equal(t, serviceauth.ReasonSignature, serviceauth.ReasonOf(err))
+}
+
+func TestParseJWKS_SkipsUnsupportedKeys(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, map[string]*ecdsa.PrivateKey{"ec": key})
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var raw map[string][]map[string]string
+	require.NoError(t, json.Unmarshal(data, &raw))
+
+	raw["keys"] = append(raw["keys"],
+		map[string]string{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+		map[string]string{"kty": ktyEC, "kid": "secp", "crv": "secp256k1", "x": "AA", "y": "AA"},
+	)
+
+	data, err = json.Marshal(raw)
+	require.NoError(t, err)
+
+	ks, err := ParseJWKS(data)
+	require.NoError(t, err)
+	assert.Len(t, ks.keys, 1)
+	assert.Contains(t, ks.byKid, "ec")
+
+	_, err = ParseJWKS([]byte(`{"keys":[{"kty":"OKP","crv":"Ed25519","x":"AA"}]}`))
+	require.Error(t, err)
+}
@@ -0,0 +1,192 @@
+package jwtauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+
+	"github.com/go-faster/errors"
+)
+
+const (
+	ktyRSA = "RSA"
+	ktyEC  = "EC"
+	ktyOct = "oct"
+
+	curveP256 = "P-256"
+	curveP384 = "P-384"
+	curveP521 = "P-521"
+)
+
+var (
+	errUnsupportedKeyType = errors.New("unsupported key type")
+	errUnsupportedCurve   = errors.New("unsupported curve")
+	errInvalidECPoint     = errors.New("invalid EC point")
+	errNoUsableKeys       = errors.New("no usable keys")
+)
+
+// jsonWebKey is a single key of RFC 7517 key set
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	// oct
+	K string `json:"k"`
+}
+
+// KeySet is a parsed JWKS, keys are *rsa.PublicKey, *ecdsa.PublicKey or []byte
+type KeySet struct {
+	byKid map[string]any
+	keys  []any
+}
+
+// ParseJWKS parses JSON Web Key Set. Keys with use other than "sig" and keys of
+// unsupported types or curves (e.g. OKP) are skipped, the set must contain at
+// least one usable key.
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var raw struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, errors.Wrap(err, "unmarshal jwks")
+	}
+
+	ks := &KeySet{byKid: make(map[string]any, len(raw.Keys))}
+
+	for _, jwk := range raw.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if errors.Is(err, errUnsupportedKeyType) || errors.Is(err, errUnsupportedCurve) {
+			continue
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "key %q", jwk.Kid)
+		}
+
+		if jwk.Kid != "" {
+			ks.byKid[jwk.Kid] = key
+		}
+
+		ks.keys = append(ks.keys, key)
+	}
+
+	if len(ks.keys) == 0 {
+		return nil, errNoUsableKeys
+	}
+
+	return ks, nil
+}
+
+// lookup returns key by kid. Without kid the only key accepted by match is returned.
+func (ks *KeySet) lookup(kid string, match func(any) bool) (any, bool) {
+	if ks == nil {
+		return nil, false
+	}
+
+	if kid != "" {
+		key, ok := ks.byKid[kid]
+		if !ok || !match(key) {
+			return nil, false
+		}
+
+		return key, true
+	}
+
+	var found any
+
+	for _, key := range ks.keys {
+		if !match(key) {
+			continue
+		}
+
+		if found != nil {
+			// ambiguous, kid is required
+			return nil, false
+		}
+
+		found = key
+	}
+
+	return found, found != nil
+}
+
+func (k jsonWebKey) publicKey() (any, error) {
+	switch k.Kty {
+	case ktyRSA:
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode n")
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode e")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case ktyEC:
+		var curve elliptic.Curve
+
+		switch k.Crv {
+		case curveP256:
+			curve = elliptic.P256()
+		case curveP384:
+			curve = elliptic.P384()
+		case curveP521:
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Wrap(errUnsupportedCurve, k.Crv)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode x")
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode y")
+		}
+
+		//nolint:staticcheck // there is no non-deprecated way to validate raw coordinates
+		if !curve.IsOnCurve(x, y) {
+			return nil, errInvalidECPoint
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case ktyOct:
+		key, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode k")
+		}
+
+		return key, nil
+	default:
+		return nil, errors.Wrap(errUnsupportedKeyType, k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
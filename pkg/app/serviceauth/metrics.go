@@ -0,0 +1,100 @@
+package serviceauth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-faster/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+)
+
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+// Metrics counts authentication results. Several authorizers may share
+// one registry, the collector is registered once and reused.
+type Metrics struct {
+	requests *prometheus.CounterVec
+}
+
+// NewMetrics creates metrics and registers them in m. Nil registry disables registration.
+func NewMetrics(m *prometheus.Registry) (*Metrics, error) {
+	requests := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "auth",
+			Name:      "requests_total",
+			Help:      "Total number of authentication attempts by authorizer, result, failure reason and actor kind",
+		},
+		[]string{"authorizer", "result", "reason", "kind"},
+	)
+
+	requests, err := Register(m, requests)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Metrics{requests: requests}, nil
+}
+
+// Register registers c in m or returns the collector registered before,
+// so authorizers sharing a registry can register the same metrics.
+// Nil registry disables registration.
+func Register[T prometheus.Collector](m *prometheus.Registry, c T) (T, error) {
+	if m == nil {
+		return c, nil
+	}
+
+	err := m.Register(c)
+	if err == nil {
+		return c, nil
+	}
+
+	var are prometheus.AlreadyRegisteredError
+	if !errors.As(err, &are) {
+		return c, errors.Wrap(err, "can't register auth metrics")
+	}
+
+	existing, ok := are.ExistingCollector.(T)
+	if !ok {
+		return c, errors.Wrap(err, "auth metrics registered with another type")
+	}
+
+	return existing, nil
+}
+
+// Observe counts and audits result of authentication by authorizer, act is
+// the authenticated actor. Prefer ObserveRequest or ObserveContext, audit
+// events of Observe carry no request details.
+func (m *Metrics) Observe(authorizer string, act ds.Actor, err error) {
+	m.ObserveContext(context.Background(), authorizer, act, err)
+}
+
+// ObserveRequest counts and audits result of authentication of r
+func (m *Metrics) ObserveRequest(r *http.Request, authorizer string, act ds.Actor, err error) {
+	m.count(authorizer, act, err)
+	AuditRequest(r, authorizer, act, err)
+}
+
+// ObserveContext counts and audits result of authentication without a request
+func (m *Metrics) ObserveContext(ctx context.Context, authorizer string, act ds.Actor, err error) {
+	m.count(authorizer, act, err)
+	AuditContext(ctx, authorizer, act, err)
+}
+
+func (m *Metrics) count(authorizer string, act ds.Actor, err error) {
+	if m == nil {
+		return
+	}
+
+	if err == nil {
+		m.requests.WithLabelValues(authorizer, resultSuccess, "", string(actor.KindOf(act))).Inc()
+		return
+	}
+
+	m.requests.WithLabelValues(authorizer, resultFailure, ReasonOf(err), "").Inc()
+}
@@ -0,0 +1,366 @@
+// Package mtlsauth implements ds.Authorizer deriving the actor from the mTLS
+// client certificate.
+//
+// The certificate chain is verified against a CA bundle and CRLs, both
+// reloaded from disk when they change. A CRL applies to certificates of its
+// issuer (a CA of the bundle or an intermediate) only if it is signed by the
+// issuer of the verified chain, so CRLs of a rotated CA are not trusted. A CRL
+// past its NextUpdate rejects certificates of its issuer until it is renewed. The caller is identified by the SPIFFE
+// URI SAN or, if there is none, by the subject CN and mapped to an actor
+// through the identity table. The actor is *actor.ServiceActor carrying the
+// service name, so handlers can authorize by caller service.
+package mtlsauth
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+)
+
+// Name of the authorizer in metrics
+const Name = "mtls"
+
+// Failure reasons specific to client certificates
+const (
+	ReasonUntrusted = "untrusted"
+	ReasonRevoked   = "revoked"
+	// ReasonStaleCRL means the CRL of an issuer of the chain is past its NextUpdate
+	ReasonStaleCRL = "stale_crl"
+)
+
+const (
+	spiffeScheme = "spiffe"
+
+	pemTypeCertificate = "CERTIFICATE"
+	pemTypeCRL         = "X509 CRL"
+)
+
+var (
+	errNoCA          = errors.New("CA bundle file is not configured")
+	errEmptyCABundle = errors.New("CA bundle contains no certificates")
+	errStaleCRL      = errors.New("CRL is past its next update")
+	errUnknownIdent  = errors.New("identity is not in the table")
+)
+
+// Identity is an entry of the identity table
+type Identity struct {
+	// ActorID of the service actor, it is identified by the identity if zero
+	ActorID int64  `json:"actor_id"`
+	Service string `json:"service"`
+}
+
+// Config of the mTLS authorizer
+type Config struct {
+	// CABundleFile is a PEM file with trusted CA certificates
+	CABundleFile string
+	// CRLFile is an optional DER CRL or PEM CRLs of CAs and intermediates
+	CRLFile string
+	// ReloadInterval is how often files are checked for changes, 10 seconds by default
+	ReloadInterval time.Duration
+
+	// Identities maps SPIFFE IDs ("spiffe://cluster/ns/billing/sa/api") or CNs to actors
+	Identities map[string]Identity
+
+	// Now is used in tests
+	Now func() time.Time
+}
+
+type caBundle struct {
+	pool  *x509.CertPool
+	certs []*x509.Certificate
+}
+
+// revocationList is a CRL with revoked serials
+type revocationList struct {
+	crl     *x509.RevocationList
+	revoked map[string]struct{}
+
+	// signer is the last issuer certificate the signature was verified with
+	signer atomic.Pointer[x509.Certificate]
+}
+
+// signedBy checks the signature of the CRL, the result for the last issuer is cached
+func (l *revocationList) signedBy(issuer *x509.Certificate) bool {
+	if signer := l.signer.Load(); signer != nil && signer.Equal(issuer) {
+		return true
+	}
+
+	if l.crl.CheckSignatureFrom(issuer) != nil {
+		return false
+	}
+
+	l.signer.Store(issuer)
+
+	return true
+}
+
+// revocationLists holds CRLs by raw issuer, a rotated CA may keep its name
+type revocationLists map[string][]*revocationList
+
+// Authorizer authenticates callers by client certificates
+type Authorizer struct {
+	cfg Config
+
+	mu         sync.RWMutex
+	identities map[string]Identity
+
+	ca      *serviceauth.FileSource[*caBundle]
+	crl     *serviceauth.FileSource[revocationLists]
+	metrics *serviceauth.Metrics
+}
+
+var _ ds.Authorizer = (*Authorizer)(nil)
+
+// New creates the authorizer, files are loaded in Init
+func New(cfg Config) *Authorizer {
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	return &Authorizer{
+		cfg:        cfg,
+		identities: cfg.Identities,
+	}
+}
+
+func (a *Authorizer) Init(_ context.Context, _ []ds.Runnable, m *prometheus.Registry) (ds.Authorizer, error) {
+	if a.cfg.CABundleFile == "" {
+		return nil, errNoCA
+	}
+
+	ca, err := serviceauth.NewFileSource(a.cfg.CABundleFile, a.cfg.ReloadInterval, parseCABundle)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't load CA bundle")
+	}
+
+	a.ca = ca
+
+	if a.cfg.CRLFile != "" {
+		crl, err := serviceauth.NewFileSource(a.cfg.CRLFile, a.cfg.ReloadInterval, a.parseCRL)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't load CRL")
+		}
+
+		a.crl = crl
+	}
+
+	metrics, err := serviceauth.NewMetrics(m)
+	if err != nil {
+		return nil, err
+	}
+
+	a.metrics = metrics
+
+	return a, nil
+}
+
+// SetIdentities atomically replaces the identity table
+func (a *Authorizer) SetIdentities(identities map[string]Identity) {
+	a.mu.Lock()
+	a.identities = identities
+	a.mu.Unlock()
+}
+
+func (a *Authorizer) AuthRest(r *http.Request) (ds.Actor, error) {
+	act, err := a.authenticate(r)
+	a.metrics.ObserveRequest(r, Name, act, err)
+
+	return act, err
+}
+
+// CheckCSRF always succeeds: client certificates are not a cookie based credential
+// for service-to-service calls
+func (a *Authorizer) CheckCSRF(_ *http.Request) (bool, error) {
+	return true, nil
+}
+
+// HasCredentials reports whether the client presented a certificate
+func (a *Authorizer) HasCredentials(r *http.Request) bool {
+	return r.TLS != nil && len(r.TLS.PeerCertificates) > 0
+}
+
+func (a *Authorizer) authenticate(r *http.Request) (ds.Actor, error) {
+	if !a.HasCredentials(r) {
+		return nil, serviceauth.NoCredentials()
+	}
+
+	leaf := r.TLS.PeerCertificates[0]
+	now := a.cfg.Now()
+
+	if now.After(leaf.NotAfter) {
+		return nil, serviceauth.Invalid(serviceauth.ReasonExpired, nil)
+	}
+
+	if now.Before(leaf.NotBefore) {
+		return nil, serviceauth.Invalid(serviceauth.ReasonNotYetValid, nil)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range r.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         a.ca.Get().pool,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil, serviceauth.Invalid(ReasonUntrusted, err)
+	}
+
+	if err := a.checkRevocation(chains, now); err != nil {
+		return nil, err
+	}
+
+	ident := identityOf(leaf)
+
+	a.mu.RLock()
+	entry, ok := a.identities[ident]
+	a.mu.RUnlock()
+
+	if !ok {
+		return nil, serviceauth.Invalid(serviceauth.ReasonUnknownActor, errors.Wrap(errUnknownIdent, ident))
+	}
+
+	act := &actor.ServiceActor{
+		Actor:    actor.Actor{ID: entry.ActorID, Kind: actor.KindService, Name: entry.Service, ExpiresAt: leaf.NotAfter},
+		Service:  entry.Service,
+		Identity: ident,
+	}
+	if entry.ActorID == 0 {
+		act.StringID = ident
+	}
+
+	return act, nil
+}
+
+// checkRevocation checks all non-root certificates of verified chains against
+// CRLs signed by their issuers
+func (a *Authorizer) checkRevocation(chains [][]*x509.Certificate, now time.Time) error {
+	if a.crl == nil {
+		return nil
+	}
+
+	lists := a.crl.Get()
+
+	for _, chain := range chains {
+		for i, cert := range chain[:len(chain)-1] {
+			for _, list := range lists[string(cert.RawIssuer)] {
+				if !list.signedBy(chain[i+1]) {
+					continue
+				}
+
+				if !list.crl.NextUpdate.IsZero() && now.After(list.crl.NextUpdate) {
+					return serviceauth.Invalid(ReasonStaleCRL, errStaleCRL)
+				}
+
+				if _, ok := list.revoked[cert.SerialNumber.String()]; ok {
+					return serviceauth.Invalid(ReasonRevoked, nil)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// identityOf returns SPIFFE ID from URI SAN or subject CN
+func identityOf(cert *x509.Certificate) string {
+	for _, uri := range cert.URIs {
+		if uri.Scheme == spiffeScheme {
+			return uri.String()
+		}
+	}
+
+	return cert.Subject.CommonName
+}
+
+func parseCABundle(data []byte) (*caBundle, error) {
+	bundle := &caBundle{pool: x509.NewCertPool()}
+
+	for {
+		var block *pem.Block
+
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		if block.Type != pemTypeCertificate {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse CA certificate")
+		}
+
+		bundle.pool.AddCert(cert)
+		bundle.certs = append(bundle.certs, cert)
+	}
+
+	if len(bundle.certs) == 0 {
+		return nil, errEmptyCABundle
+	}
+
+	return bundle, nil
+}
+
+// parseCRL parses a DER CRL or PEM CRLs of several issuers. Signatures are
+// checked against issuers of verified chains, CRLs past NextUpdate are rejected.
+func (a *Authorizer) parseCRL(data []byte) (revocationLists, error) {
+	var ders [][]byte
+
+	for rest := data; ; {
+		var block *pem.Block
+
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type == pemTypeCRL {
+			ders = append(ders, block.Bytes)
+		}
+	}
+
+	if len(ders) == 0 {
+		ders = append(ders, data)
+	}
+
+	now := a.cfg.Now()
+	lists := make(revocationLists)
+
+	for _, der := range ders {
+		crl, err := x509.ParseRevocationList(der)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse CRL")
+		}
+
+		if !crl.NextUpdate.IsZero() && now.After(crl.NextUpdate) {
+			return nil, errors.Wrap(errStaleCRL, crl.Issuer.String())
+		}
+
+		list := &revocationList{crl: crl, revoked: make(map[string]struct{}, len(crl.RevokedCertificateEntries))}
+		for _, entry := range crl.RevokedCertificateEntries {
+			list.revoked[entry.SerialNumber.String()] = struct{}{}
+		}
+
+		lists[string(crl.RawIssuer)] = append(lists[string(crl.RawIssuer)], list)
+	}
+
+	return lists, nil
+}
@@ -0,0 +1,269 @@
+package mtlsauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newCA(t *testing.T) testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return testCA{cert: cert, key: key}
+}
+
+func (ca testCA) issue(t *testing.T, serial int64, cn, spiffeID string, notAfter time.Time) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	if spiffeID != "" {
+		u, err := url.Parse(spiffeID)
+		require.NoError(t, err)
+
+		tmpl.URIs = []*url.URL{u}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert
+}
+
+// intermediate issues an intermediate CA
+func (ca testCA) intermediate(t *testing.T, serial int64) testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "test intermediate CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return testCA{cert: cert, key: key}
+}
+
+func (ca testCA) crl(t *testing.T, serials ...int64) []byte {
+	t.Helper()
+
+	return ca.crlUntil(t, time.Now().Add(time.Hour), serials...)
+}
+
+func (ca testCA) crlUntil(t *testing.T, nextUpdate time.Time, serials ...int64) []byte {
+	t.Helper()
+
+	tmpl := &x509.RevocationList{
+		Number:     big.NewInt(time.Now().UnixNano()),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: nextUpdate,
+	}
+
+	for _, serial := range serials {
+		tmpl.RevokedCertificateEntries = append(tmpl.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: time.Now(),
+		})
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, tmpl, ca.cert, ca.key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: pemTypeCRL, Bytes: der})
+}
+
+func TestAuthorizer(t *testing.T) {
+	ca := newCA(t)
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	crlFile := filepath.Join(dir, "crl.pem")
+
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: pemTypeCertificate, Bytes: ca.cert.Raw}), 0o600))
+	require.NoError(t, os.WriteFile(crlFile, ca.crl(t), 0o600))
+
+	a := New(Config{
+		CABundleFile:   caFile,
+		CRLFile:        crlFile,
+		ReloadInterval: time.Nanosecond,
+		Identities: map[string]Identity{
+			"spiffe://cluster/ns/billing/sa/api": {ActorID: 10, Service: "billing"},
+			"crm":                                {ActorID: 11, Service: "crm"},
+			"search":                             {Service: "search"},
+		},
+	})
+	_, err := a.Init(context.Background(), nil, nil)
+	require.NoError(t, err)
+
+	auth := func(certs ...*x509.Certificate) (*actor.ServiceActor, error) {
+		r := httptest.NewRequest("GET", "/", nil)
+		if certs != nil {
+			r.TLS = &tls.ConnectionState{PeerCertificates: certs}
+		}
+
+		act, err := a.AuthRest(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return act.(*actor.ServiceActor), nil
+	}
+
+	_, err = auth()
+	require.ErrorIs(t, err, serviceauth.ErrNoCredentials)
+
+	billing := ca.issue(t, 100, "ignored", "spiffe://cluster/ns/billing/sa/api", time.Now().Add(time.Hour))
+	act, err := auth(billing)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), act.GetID())
+	assert.Equal(t, "billing", act.GetService())
+
+	act, err = auth(ca.issue(t, 101, "crm", "", time.Now().Add(time.Hour)))
+	require.NoError(t, err)
+	assert.Equal(t, "crm", act.GetService())
+	assert.Equal(t, actor.KindService, act.GetKind())
+
+	// identity without actor ID is identified by the identity
+	act, err = auth(ca.issue(t, 105, "search", "", time.Now().Add(time.Hour)))
+	require.NoError(t, err)
+	assert.Equal(t, "search", act.GetStringID())
+
+	_, err = reqctx.SetActor(context.Background(), act)
+	require.NoError(t, err)
+
+	_, err = auth(ca.issue(t, 102, "unknown", "", time.Now().Add(time.Hour)))
+	assert.Equal(t, serviceauth.ReasonUnknownActor, serviceauth.ReasonOf(err))
+
+	_, err = auth(ca.issue(t, 103, "crm", "", time.Now().Add(-time.Minute)))
+	assert.Equal(t, serviceauth.ReasonExpired, serviceauth.ReasonOf(err))
+
+	_, err = auth(newCA(t).issue(t, 104, "crm", "", time.Now().Add(time.Hour)))
+	assert.Equal(t, ReasonUntrusted, serviceauth.ReasonOf(err))
+
+	// revoke billing certificate, CRL is reloaded from disk
+	require.NoError(t, os.WriteFile(crlFile, ca.crl(t, 100), 0o600))
+
+	_, err = auth(billing)
+	assert.Equal(t, ReasonRevoked, serviceauth.ReasonOf(err))
+}
+
+func TestAuthorizer_CRL(t *testing.T) {
+	root := newCA(t)
+	inter := root.intermediate(t, 2)
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	crlFile := filepath.Join(dir, "crl.pem")
+	now := time.Now()
+
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: pemTypeCertificate, Bytes: root.cert.Raw}), 0o600))
+	// CRLs of the root and the intermediate in one file
+	require.NoError(t, os.WriteFile(crlFile, append(root.crl(t), inter.crlUntil(t, now.Add(time.Hour), 100)...), 0o600))
+
+	a := New(Config{
+		CABundleFile:   caFile,
+		CRLFile:        crlFile,
+		ReloadInterval: time.Nanosecond,
+		Identities:     map[string]Identity{"crm": {ActorID: 11, Service: "crm"}},
+		Now:            func() time.Time { return now },
+	})
+	_, err := a.Init(context.Background(), nil, nil)
+	require.NoError(t, err)
+
+	auth := func(certs ...*x509.Certificate) error {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.TLS = &tls.ConnectionState{PeerCertificates: certs}
+
+		_, err := a.AuthRest(r)
+
+		return err
+	}
+
+	// certificates of intermediates are revoked by their CRLs
+	require.NoError(t, auth(inter.issue(t, 101, "crm", "", now.Add(2*time.Hour)), inter.cert))
+
+	revoked := inter.issue(t, 100, "crm", "", now.Add(2*time.Hour))
+	assert.Equal(t, ReasonRevoked, serviceauth.ReasonOf(auth(revoked, inter.cert)))
+
+	// a stale CRL is not trusted
+	now = now.Add(90 * time.Minute)
+	assert.Equal(t, ReasonStaleCRL, serviceauth.ReasonOf(auth(inter.issue(t, 102, "crm", "", now.Add(time.Hour)), inter.cert)))
+
+	_, err = a.parseCRL(inter.crlUntil(t, now.Add(-time.Minute)))
+	require.ErrorIs(t, err, errStaleCRL)
+
+	// after rotation of the CA with the same name the CRL of the old one doesn't apply
+	now = time.Now()
+	rotated := newCA(t)
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: pemTypeCertificate, Bytes: rotated.cert.Raw}), 0o600))
+	require.NoError(t, os.WriteFile(crlFile, append(root.crl(t, 103), rotated.crl(t, 104)...), 0o600))
+
+	require.NoError(t, auth(rotated.issue(t, 103, "crm", "", now.Add(time.Hour))))
+	assert.Equal(t, ReasonRevoked, serviceauth.ReasonOf(auth(rotated.issue(t, 104, "crm", "", now.Add(time.Hour)))))
+	assert.Equal(t, ReasonUntrusted, serviceauth.ReasonOf(auth(root.issue(t, 105, "crm", "", now.Add(time.Hour)))))
+}
@@ -0,0 +1,359 @@
+// Package session implements ds.Authorizer for browser facing services using
+// cookie sessions.
+//
+// The cookie contains only the session ID encrypted and authenticated with
+// AES-GCM under rotating keys; session state lives in a Store. Sessions have
+// sliding idle expiration and an absolute lifetime. Login always issues a new
+// session ID, which protects from session fixation.
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth/csrf"
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
+)
+
+// Name of the authorizer in metrics
+const Name = "session"
+
+const (
+	defaultCookieName      = "session"
+	defaultIdleTimeout     = 30 * time.Minute
+	defaultAbsoluteTimeout = 24 * time.Hour
+	// LastSeen is not written on every request to spare the store
+	defaultTouchInterval = time.Minute
+
+	sessionIDSize = 32
+)
+
+var (
+	errNoStore      = errors.New("session store is not configured")
+	errInvalidActor = errors.New("invalid actor")
+)
+
+// Config of the session authorizer
+type Config struct {
+	Store Store
+
+	// Keys encrypt cookies, the first one is active
+	Keys []Key
+
+	CookieName     string
+	CookieDomain   string
+	CookiePath     string
+	CookieSecure   bool
+	CookieSameSite http.SameSite
+
+	// IdleTimeout expires sessions without requests, 30 minutes by default
+	IdleTimeout time.Duration
+	// AbsoluteTimeout limits session lifetime regardless of activity, 24 hours by default
+	AbsoluteTimeout time.Duration
+	// TouchInterval is the minimal interval between LastSeen updates, 1 minute by default
+	TouchInterval time.Duration
+
+	// CSRF checks unsafe requests. Without it CheckCSRF relies on the SameSite cookie attribute.
+	CSRF *csrf.Protector
+
+	// Now is used in tests
+	Now func() time.Time
+}
+
+// Authorizer authenticates requests by session cookie
+type Authorizer struct {
+	cfg     Config
+	sealer  *sealer
+	metrics *serviceauth.Metrics
+}
+
+var _ ds.Authorizer = (*Authorizer)(nil)
+
+// New creates the authorizer
+func New(cfg Config) (*Authorizer, error) {
+	if cfg.Store == nil {
+		return nil, errNoStore
+	}
+
+	sealer, err := newSealer(cfg.Keys)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.CookieName == "" {
+		cfg.CookieName = defaultCookieName
+	}
+
+	if cfg.CookiePath == "" {
+		cfg.CookiePath = "/"
+	}
+
+	if cfg.CookieSameSite == 0 {
+		cfg.CookieSameSite = http.SameSiteLaxMode
+	}
+
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = defaultIdleTimeout
+	}
+
+	if cfg.AbsoluteTimeout <= 0 {
+		cfg.AbsoluteTimeout = defaultAbsoluteTimeout
+	}
+
+	if cfg.TouchInterval <= 0 {
+		cfg.TouchInterval = defaultTouchInterval
+	}
+
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	return &Authorizer{cfg: cfg, sealer: sealer}, nil
+}
+
+func (a *Authorizer) Init(_ context.Context, _ []ds.Runnable, m *prometheus.Registry) (ds.Authorizer, error) {
+	metrics, err := serviceauth.NewMetrics(m)
+	if err != nil {
+		return nil, err
+	}
+
+	a.metrics = metrics
+
+	return a, nil
+}
+
+func (a *Authorizer) AuthRest(r *http.Request) (ds.Actor, error) {
+	s, err := a.load(r)
+	if err != nil {
+		a.metrics.ObserveRequest(r, Name, nil, err)
+		return nil, err
+	}
+
+	act := a.actor(s)
+	a.metrics.ObserveRequest(r, Name, act, nil)
+
+	return act, nil
+}
+
+// actor restores the actor of s
+func (a *Authorizer) actor(s *Session) *actor.Actor {
+	return &actor.Actor{
+		ID:         s.ActorID,
+		Kind:       s.ActorKind,
+		StringID:   s.ActorStringID,
+		Name:       s.ActorName,
+		Roles:      s.ActorRoles,
+		Scopes:     s.ActorScopes,
+		Attributes: s.ActorAttributes,
+		ExpiresAt:  a.cfg.Now().Add(a.ttl(s)),
+	}
+}
+
+// setIdentity stores the identity of act in s. Attributes are stored for
+// *actor.Actor and *actor.ServiceActor only, other actors don't expose them.
+func setIdentity(s *Session, act ds.Actor) {
+	s.ActorID = act.GetID()
+	s.ActorKind = actor.KindOf(act)
+
+	if p, ok := act.(actor.StringIDProvider); ok {
+		s.ActorStringID = p.GetStringID()
+	}
+
+	if p, ok := act.(interface{ GetName() string }); ok {
+		s.ActorName = p.GetName()
+	}
+
+	if p, ok := act.(interface{ GetRoles() []string }); ok {
+		s.ActorRoles = p.GetRoles()
+	}
+
+	if p, ok := act.(interface{ GetScopes() []string }); ok {
+		s.ActorScopes = p.GetScopes()
+	}
+
+	switch v := act.(type) {
+	case *actor.Actor:
+		s.ActorAttributes = v.Attributes
+	case *actor.ServiceActor:
+		s.ActorAttributes = v.Attributes
+	}
+}
+
+// CheckCSRF uses Config.CSRF if set, tokens are bound to the actor of the session
+func (a *Authorizer) CheckCSRF(r *http.Request) (bool, error) {
+	if a.cfg.CSRF == nil {
+		return true, nil
+	}
+
+	return a.cfg.CSRF.CheckWith(r, a.csrfSession)
+}
+
+func (a *Authorizer) csrfSession(r *http.Request) string {
+	if session := csrf.ActorSession(r); session != "" {
+		return session
+	}
+
+	s, err := a.load(r)
+	if err != nil {
+		return ""
+	}
+
+	return csrf.SessionOf(a.actor(s))
+}
+
+// HasCredentials reports whether the request carries the session cookie
+func (a *Authorizer) HasCredentials(r *http.Request) bool {
+	cookie, err := r.Cookie(a.cfg.CookieName)
+
+	return err == nil && cookie.Value != ""
+}
+
+// Current returns the session of the request
+func (a *Authorizer) Current(r *http.Request) (*Session, error) {
+	return a.load(r)
+}
+
+// load reads, validates and touches the session of r
+func (a *Authorizer) load(r *http.Request) (*Session, error) {
+	id, err := a.sessionID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := a.cfg.Store.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, serviceauth.Invalid(serviceauth.ReasonUnknownActor, err)
+		}
+
+		return nil, serviceauth.Invalid(serviceauth.ReasonInternal, err)
+	}
+
+	now := a.cfg.Now()
+
+	if now.Sub(s.LastSeen) > a.cfg.IdleTimeout || now.Sub(s.CreatedAt) > a.cfg.AbsoluteTimeout {
+		_ = a.cfg.Store.Delete(r.Context(), id)
+		return nil, serviceauth.Invalid(serviceauth.ReasonExpired, nil)
+	}
+
+	if now.Sub(s.LastSeen) >= a.cfg.TouchInterval {
+		s.LastSeen = now
+		if err = a.cfg.Store.Save(r.Context(), s, a.ttl(s)); err != nil {
+			return nil, serviceauth.Invalid(serviceauth.ReasonInternal, err)
+		}
+	}
+
+	return s, nil
+}
+
+// sessionID decrypts session ID from the cookie
+func (a *Authorizer) sessionID(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(a.cfg.CookieName)
+	if err != nil || cookie.Value == "" {
+		return "", serviceauth.NoCredentials()
+	}
+
+	id, err := a.sealer.open(cookie.Value, a.cfg.CookieName)
+	if err != nil {
+		if errors.Is(err, errUnknownKey) {
+			return "", serviceauth.Invalid(serviceauth.ReasonUnknownKey, err)
+		}
+
+		return "", serviceauth.Invalid(serviceauth.ReasonMalformed, err)
+	}
+
+	return id, nil
+}
+
+// ttl is the time the store should keep the session
+func (a *Authorizer) ttl(s *Session) time.Duration {
+	now := a.cfg.Now()
+
+	return min(s.LastSeen.Add(a.cfg.IdleTimeout).Sub(now), s.CreatedAt.Add(a.cfg.AbsoluteTimeout).Sub(now))
+}
+
+// Login creates a new session for act, sets the cookie and returns the request
+// context with the actor set by reqctx.SetActor. A session referenced by the
+// request cookie is destroyed, so an ID planted by an attacker is never reused.
+func (a *Authorizer) Login(w http.ResponseWriter, r *http.Request, act ds.Actor) (context.Context, error) {
+	if act == nil || actor.KindOf(act) == actor.KindAnonymous || act.GetID() == 0 && stringID(act) == "" {
+		return nil, errInvalidActor
+	}
+
+	if oldID, err := a.sessionID(r); err == nil {
+		if err = a.cfg.Store.Delete(r.Context(), oldID); err != nil {
+			return nil, errors.Wrap(err, "delete previous session")
+		}
+	}
+
+	raw := make([]byte, sessionIDSize)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, errors.Wrap(err, "generate session ID")
+	}
+
+	now := a.cfg.Now()
+	s := &Session{
+		ID:        base64.RawURLEncoding.EncodeToString(raw),
+		CreatedAt: now,
+		LastSeen:  now,
+	}
+
+	setIdentity(s, act)
+
+	if err := a.cfg.Store.Save(r.Context(), s, a.ttl(s)); err != nil {
+		return nil, errors.Wrap(err, "save session")
+	}
+
+	value, err := a.sealer.seal(s.ID, a.cfg.CookieName)
+	if err != nil {
+		return nil, err
+	}
+
+	http.SetCookie(w, a.cookie(value, int(a.cfg.AbsoluteTimeout.Seconds())))
+
+	return reqctx.SetActor(r.Context(), act)
+}
+
+func stringID(act ds.Actor) string {
+	if p, ok := act.(actor.StringIDProvider); ok {
+		return p.GetStringID()
+	}
+
+	return ""
+}
+
+// Logout destroys the session of the request and clears the cookie
+func (a *Authorizer) Logout(w http.ResponseWriter, r *http.Request) error {
+	id, err := a.sessionID(r)
+	if err == nil {
+		if err = a.cfg.Store.Delete(r.Context(), id); err != nil {
+			return errors.Wrap(err, "delete session")
+		}
+	}
+
+	http.SetCookie(w, a.cookie("", -1))
+
+	return nil
+}
+
+func (a *Authorizer) cookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     a.cfg.CookieName,
+		Value:    value,
+		Path:     a.cfg.CookiePath,
+		Domain:   a.cfg.CookieDomain,
+		MaxAge:   maxAge,
+		Secure:   a.cfg.CookieSecure,
+		HttpOnly: true,
+		SameSite: a.cfg.CookieSameSite,
+	}
+}
@@ -0,0 +1,181 @@
+package session
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
+)
+
+func newAuthorizer(t *testing.T, store Store, keys []Key, now *time.Time) *Authorizer {
+	t.Helper()
+
+	a, err := New(Config{
+		Store:           store,
+		Keys:            keys,
+		IdleTimeout:     10 * time.Minute,
+		AbsoluteTimeout: time.Hour,
+		Now:             func() time.Time { return *now },
+	})
+	require.NoError(t, err)
+
+	_, err = a.Init(context.Background(), nil, nil)
+	require.NoError(t, err)
+
+	return a
+}
+
+func login(t *testing.T, a *Authorizer, r *http.Request, id int64) *http.Cookie {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+
+	ctx, err := a.Login(w, r, &actor.Actor{ID: id})
+	require.NoError(t, err)
+
+	act, err := reqctx.GetActor(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, id, act.GetID())
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, cookies[0].HttpOnly)
+
+	return cookies[0]
+}
+
+func requestWith(cookie *http.Cookie) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+
+	return r
+}
+
+func TestAuthorizer_Lifecycle(t *testing.T) {
+	for name, store := range map[string]Store{
+		"memory": NewMemoryStore(),
+		"file":   must(NewFileStore(t.TempDir())),
+	} {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			a := newAuthorizer(t, store, []Key{{ID: "k1", Secret: []byte("secret")}}, &now)
+
+			_, err := a.AuthRest(requestWith(nil))
+			require.ErrorIs(t, err, serviceauth.ErrNoCredentials)
+
+			cookie := login(t, a, requestWith(nil), 5)
+
+			act, err := a.AuthRest(requestWith(cookie))
+			require.NoError(t, err)
+			assert.Equal(t, int64(5), act.GetID())
+
+			// sliding expiration: activity every 8 minutes keeps the session alive
+			for range 3 {
+				now = now.Add(8 * time.Minute)
+				_, err = a.AuthRest(requestWith(cookie))
+				require.NoError(t, err)
+			}
+
+			// idle timeout
+			now = now.Add(11 * time.Minute)
+			_, err = a.AuthRest(requestWith(cookie))
+			assert.Equal(t, serviceauth.ReasonExpired, serviceauth.ReasonOf(err))
+
+			// absolute timeout
+			cookie = login(t, a, requestWith(nil), 5)
+			for range 8 {
+				now = now.Add(8 * time.Minute)
+				_, err = a.AuthRest(requestWith(cookie))
+			}
+			assert.Equal(t, serviceauth.ReasonExpired, serviceauth.ReasonOf(err))
+
+			// logout
+			cookie = login(t, a, requestWith(nil), 5)
+			require.NoError(t, a.Logout(httptest.NewRecorder(), requestWith(cookie)))
+			_, err = a.AuthRest(requestWith(cookie))
+			require.ErrorIs(t, err, serviceauth.ErrInvalidCredentials)
+		})
+	}
+}
+
+func TestAuthorizer_FixationAndKeyRotation(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore()
+	a := newAuthorizer(t, store, []Key{{ID: "old", Secret: []byte("old-secret")}}, &now)
+
+	planted := login(t, a, requestWith(nil), 1)
+
+	// login with a planted cookie issues a new ID and destroys the old session
+	fresh := login(t, a, requestWith(planted), 2)
+	assert.NotEqual(t, planted.Value, fresh.Value)
+
+	_, err := a.AuthRest(requestWith(planted))
+	require.ErrorIs(t, err, serviceauth.ErrInvalidCredentials)
+
+	// new active key, old cookies are still accepted
+	rotated := newAuthorizer(t, store, []Key{{ID: "new", Secret: []byte("new-secret")}, {ID: "old", Secret: []byte("old-secret")}}, &now)
+
+	act, err := rotated.AuthRest(requestWith(fresh))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), act.GetID())
+
+	tampered := *fresh
+	tampered.Value = fresh.Value[:len(fresh.Value)-2] + "AA"
+	_, err = rotated.AuthRest(requestWith(&tampered))
+	assert.Equal(t, serviceauth.ReasonMalformed, serviceauth.ReasonOf(err))
+}
+
+func TestAuthorizer_RestoresIdentity(t *testing.T) {
+	now := time.Now()
+	a := newAuthorizer(t, must(NewFileStore(t.TempDir())), []Key{{ID: "k1", Secret: []byte("secret")}}, &now)
+
+	_, err := a.Login(httptest.NewRecorder(), requestWith(nil), actor.NewAnonymous())
+	require.Error(t, err)
+
+	_, err = a.Login(httptest.NewRecorder(), requestWith(nil), &actor.Actor{})
+	require.Error(t, err)
+
+	w := httptest.NewRecorder()
+	_, err = a.Login(w, requestWith(nil), &actor.Actor{
+		Kind:       actor.KindService,
+		StringID:   "0190f3c4-crm",
+		Name:       "CRM",
+		Roles:      []string{"admin"},
+		Scopes:     []string{"orders:read"},
+		Attributes: map[string]string{"tenant": "7"},
+	})
+	require.NoError(t, err)
+
+	act, err := a.AuthRest(requestWith(w.Result().Cookies()[0]))
+	require.NoError(t, err)
+
+	restored, ok := act.(*actor.Actor)
+	require.True(t, ok)
+	assert.Equal(t, actor.KindService, restored.GetKind())
+	assert.Equal(t, "0190f3c4-crm", restored.StringID)
+	assert.Equal(t, "CRM", restored.Name)
+	assert.Equal(t, []string{"admin"}, restored.Roles)
+	assert.Equal(t, []string{"orders:read"}, restored.Scopes)
+	assert.Equal(t, map[string]string{"tenant": "7"}, restored.Attributes)
+
+	_, err = reqctx.SetActor(context.Background(), act)
+	require.NoError(t, err)
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
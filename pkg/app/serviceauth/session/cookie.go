@@ -0,0 +1,101 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+
+	"github.com/go-faster/errors"
+)
+
+// Key encrypts session cookies. The first key of Config.Keys is used for new
+// cookies, the others are accepted until cookies issued with them expire.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+var (
+	errNoKeys       = errors.New("no cookie keys configured")
+	errInvalidKeyID = errors.New("key ID must be non-empty and must not contain '.'")
+	errBadCookie    = errors.New("malformed session cookie")
+	errUnknownKey   = errors.New("unknown cookie key")
+)
+
+type sealer struct {
+	active string
+	aeads  map[string]cipher.AEAD
+}
+
+func newSealer(keys []Key) (*sealer, error) {
+	if len(keys) == 0 {
+		return nil, errNoKeys
+	}
+
+	s := &sealer{active: keys[0].ID, aeads: make(map[string]cipher.AEAD, len(keys))}
+
+	for _, k := range keys {
+		if k.ID == "" || strings.Contains(k.ID, ".") {
+			return nil, errInvalidKeyID
+		}
+
+		// any secret length is accepted, AES-256 key is derived from it
+		derived := sha256.Sum256(k.Secret)
+
+		block, err := aes.NewCipher(derived[:])
+		if err != nil {
+			return nil, errors.Wrap(err, "create cipher")
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, errors.Wrap(err, "create GCM")
+		}
+
+		s.aeads[k.ID] = aead
+	}
+
+	return s, nil
+}
+
+// seal encrypts and authenticates session ID: "<kid>.<base64(nonce|ciphertext)>"
+func (s *sealer) seal(id, name string) (string, error) {
+	aead := s.aeads[s.active]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", errors.Wrap(err, "generate nonce")
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(id), []byte(name))
+
+	return s.active + "." + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts cookie value, the cookie name is authenticated as additional data
+func (s *sealer) open(value, name string) (string, error) {
+	kid, payload, ok := strings.Cut(value, ".")
+	if !ok {
+		return "", errBadCookie
+	}
+
+	aead, ok := s.aeads[kid]
+	if !ok {
+		return "", errUnknownKey
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return "", errBadCookie
+	}
+
+	id, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], []byte(name))
+	if err != nil {
+		return "", errBadCookie
+	}
+
+	return string(id), nil
+}
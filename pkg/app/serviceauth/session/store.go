@@ -0,0 +1,230 @@
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-faster/errors"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+)
+
+// ErrNotFound is returned by stores for unknown or expired sessions
+var ErrNotFound = errors.New("session not found")
+
+// Session is server side session state
+type Session struct {
+	ID        string            `json:"id"`
+	ActorID   int64             `json:"actor_id"`
+	CreatedAt time.Time         `json:"created_at"`
+	LastSeen  time.Time         `json:"last_seen"`
+	Data      map[string]string `json:"data,omitempty"`
+
+	// Identity of the actor, restored by AuthRest
+	ActorKind       actor.Kind        `json:"actor_kind,omitempty"`
+	ActorStringID   string            `json:"actor_sid,omitempty"`
+	ActorName       string            `json:"actor_name,omitempty"`
+	ActorRoles      []string          `json:"actor_roles,omitempty"`
+	ActorScopes     []string          `json:"actor_scopes,omitempty"`
+	ActorAttributes map[string]string `json:"actor_attributes,omitempty"`
+}
+
+// Store keeps sessions. Implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns session by ID or ErrNotFound
+	Get(ctx context.Context, id string) (*Session, error)
+	// Save creates or replaces session, the store may drop it after ttl
+	Save(ctx context.Context, s *Session, ttl time.Duration) error
+	// Delete removes session, deleting unknown session is not an error
+	Delete(ctx context.Context, id string) error
+}
+
+type memoryEntry struct {
+	session Session
+	expires time.Time
+}
+
+// MemoryStore keeps sessions in process memory
+type MemoryStore struct {
+	mu        sync.Mutex
+	sessions  map[string]memoryEntry
+	lastSweep time.Time
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+const memorySweepInterval = time.Minute
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]memoryEntry)}
+}
+
+func (m *MemoryStore) Get(_ context.Context, id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.sessions[id]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, ErrNotFound
+	}
+
+	s := entry.session
+
+	return &s, nil
+}
+
+func (m *MemoryStore) Save(_ context.Context, s *Session, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(m.lastSweep) >= memorySweepInterval {
+		for id, entry := range m.sessions {
+			if now.After(entry.expires) {
+				delete(m.sessions, id)
+			}
+		}
+
+		m.lastSweep = now
+	}
+
+	m.sessions[s.ID] = memoryEntry{session: *s, expires: now.Add(ttl)}
+
+	return nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	delete(m.sessions, id)
+	m.mu.Unlock()
+
+	return nil
+}
+
+// FileStore keeps every session in its own JSON file in a directory, so
+// sessions survive restarts of a single instance. File names are hashes of
+// session IDs, IDs themselves never reach the file system.
+type FileStore struct {
+	dir string
+}
+
+var _ Store = (*FileStore)(nil)
+
+type fileRecord struct {
+	Session Session   `json:"session"`
+	Expires time.Time `json:"expires"`
+}
+
+// NewFileStore creates a store in dir, the directory is created if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, errors.Wrap(err, "create session dir")
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+func (f *FileStore) path(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (f *FileStore) Get(_ context.Context, id string) (*Session, error) {
+	data, err := os.ReadFile(f.path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+
+		return nil, errors.Wrap(err, "read session")
+	}
+
+	var rec fileRecord
+	if err = json.Unmarshal(data, &rec); err != nil {
+		return nil, errors.Wrap(err, "unmarshal session")
+	}
+
+	if time.Now().After(rec.Expires) || rec.Session.ID != id {
+		return nil, ErrNotFound
+	}
+
+	return &rec.Session, nil
+}
+
+// Save writes the session atomically through a temporary file
+func (f *FileStore) Save(_ context.Context, s *Session, ttl time.Duration) error {
+	data, err := json.Marshal(fileRecord{Session: *s, Expires: time.Now().Add(ttl)})
+	if err != nil {
+		return errors.Wrap(err, "marshal session")
+	}
+
+	tmp, err := os.CreateTemp(f.dir, "session-*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "create session file")
+	}
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+
+		return errors.Wrap(err, "write session file")
+	}
+
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return errors.Wrap(err, "close session file")
+	}
+
+	if err = os.Rename(tmp.Name(), f.path(s.ID)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return errors.Wrap(err, "rename session file")
+	}
+
+	return nil
+}
+
+func (f *FileStore) Delete(_ context.Context, id string) error {
+	if err := os.Remove(f.path(id)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "delete session")
+	}
+
+	return nil
+}
+
+// Cleanup removes expired session files, call it periodically
+func (f *FileStore) Cleanup() error {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return errors.Wrap(err, "read session dir")
+	}
+
+	now := time.Now()
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		path := filepath.Join(f.dir, entry.Name())
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		var rec fileRecord
+		if json.Unmarshal(data, &rec) != nil || now.After(rec.Expires) {
+			_ = os.Remove(path)
+		}
+	}
+
+	return nil
+}
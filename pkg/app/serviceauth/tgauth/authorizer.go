@@ -0,0 +1,384 @@
+// Package tgauth implements ds.Authorizer for Telegram Mini Apps (WebApp
+// initData) and the Telegram Login Widget.
+//
+// Credentials are passed in the Authorization header:
+//
+//	Authorization: tma <initData>
+//	Authorization: tglogin <login widget fields as query string>
+//
+// initData is verified with HMAC-SHA256 keyed by HMAC-SHA256("WebAppData", bot token),
+// login widget payloads with HMAC-SHA256 keyed by SHA-256(bot token). The payload
+// is accepted if it is signed by any configured bot and auth_date is fresh.
+package tgauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+)
+
+// Name of the authorizer in metrics
+const Name = "telegram"
+
+// Authorization schemes
+const (
+	SchemeWebApp = "tma"
+	SchemeLogin  = "tglogin"
+)
+
+// Actor attributes set by the authorizer
+const (
+	AttributeBot      = "telegram_bot"
+	AttributeUsername = "telegram_username"
+)
+
+const (
+	defaultHeader = "Authorization"
+	defaultMaxAge = 24 * time.Hour
+	clockSkew     = time.Minute
+
+	webAppKey = "WebAppData"
+)
+
+var (
+	errNoBots       = errors.New("no bot tokens configured")
+	errEmptyToken   = errors.New("bot token is empty")
+	errNoHash       = errors.New("hash is missing")
+	errNoUser       = errors.New("user is missing")
+	errBadAuthDate  = errors.New("invalid auth_date")
+	errHashMismatch = errors.New("hash mismatch")
+	errEmptyPayload = errors.New("payload is empty")
+)
+
+// Bot is a bot the payloads may be signed for
+type Bot struct {
+	// Name is set as the AttributeBot of actors
+	Name  string
+	Token string
+}
+
+// User is the Telegram user from the payload
+type User struct {
+	ID           int64  `json:"id"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	Username     string `json:"username"`
+	LanguageCode string `json:"language_code"`
+	IsPremium    bool   `json:"is_premium"`
+	PhotoURL     string `json:"photo_url"`
+}
+
+// ActorFunc maps a verified Telegram user to an actor
+type ActorFunc func(ctx context.Context, bot string, user User) (ds.Actor, error)
+
+// Config of the Telegram authorizer
+type Config struct {
+	Bots []Bot
+
+	// Header with credentials, "Authorization" by default
+	Header string
+
+	// MaxAge is the maximal age of auth_date, 24 hours by default
+	MaxAge time.Duration
+
+	// ActorFunc maps users to actors, by default actor ID is Telegram user ID
+	ActorFunc ActorFunc
+
+	// Now is used in tests
+	Now func() time.Time
+}
+
+type botSecrets struct {
+	name   string
+	webApp []byte
+	login  []byte
+}
+
+// Authorizer verifies Telegram payloads
+type Authorizer struct {
+	cfg     Config
+	bots    atomic.Pointer[[]botSecrets]
+	metrics *serviceauth.Metrics
+}
+
+var _ ds.Authorizer = (*Authorizer)(nil)
+
+// New creates the authorizer
+func New(cfg Config) (*Authorizer, error) {
+	if cfg.Header == "" {
+		cfg.Header = defaultHeader
+	}
+
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = defaultMaxAge
+	}
+
+	if cfg.ActorFunc == nil {
+		cfg.ActorFunc = UserActor
+	}
+
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	a := &Authorizer{cfg: cfg}
+
+	if err := a.SetBots(cfg.Bots); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// SetBots atomically replaces bot tokens
+func (a *Authorizer) SetBots(bots []Bot) error {
+	if len(bots) == 0 {
+		return errNoBots
+	}
+
+	secrets := make([]botSecrets, 0, len(bots))
+
+	for _, bot := range bots {
+		if bot.Token == "" {
+			return errors.Wrapf(errEmptyToken, "bot %q", bot.Name)
+		}
+
+		mac := hmac.New(sha256.New, []byte(webAppKey))
+		mac.Write([]byte(bot.Token))
+		login := sha256.Sum256([]byte(bot.Token))
+
+		secrets = append(secrets, botSecrets{name: bot.Name, webApp: mac.Sum(nil), login: login[:]})
+	}
+
+	a.bots.Store(&secrets)
+
+	return nil
+}
+
+func (a *Authorizer) Init(_ context.Context, _ []ds.Runnable, m *prometheus.Registry) (ds.Authorizer, error) {
+	metrics, err := serviceauth.NewMetrics(m)
+	if err != nil {
+		return nil, err
+	}
+
+	a.metrics = metrics
+
+	return a, nil
+}
+
+func (a *Authorizer) AuthRest(r *http.Request) (ds.Actor, error) {
+	act, err := a.authenticate(r.Context(), r.Header.Get(a.cfg.Header))
+	a.metrics.ObserveRequest(r, Name, act, err)
+
+	return act, err
+}
+
+// CheckCSRF always succeeds: the header is not attached by browsers automatically
+func (a *Authorizer) CheckCSRF(_ *http.Request) (bool, error) {
+	return true, nil
+}
+
+// HasCredentials reports whether the request carries a Telegram payload
+func (a *Authorizer) HasCredentials(r *http.Request) bool {
+	scheme, _ := splitScheme(r.Header.Get(a.cfg.Header))
+
+	return scheme == SchemeWebApp || scheme == SchemeLogin
+}
+
+// AuthenticateWebApp verifies raw initData of a Mini App
+func (a *Authorizer) AuthenticateWebApp(ctx context.Context, initData string) (ds.Actor, error) {
+	act, err := a.verify(ctx, initData, true)
+	a.metrics.ObserveContext(ctx, Name, act, err)
+
+	return act, err
+}
+
+// AuthenticateLogin verifies Login Widget fields, e.g. query of the redirect URL
+func (a *Authorizer) AuthenticateLogin(ctx context.Context, fields url.Values) (ds.Actor, error) {
+	act, err := a.verifyValues(ctx, fields, false)
+	a.metrics.ObserveContext(ctx, Name, act, err)
+
+	return act, err
+}
+
+func (a *Authorizer) authenticate(ctx context.Context, header string) (ds.Actor, error) {
+	scheme, payload := splitScheme(header)
+
+	switch scheme {
+	case SchemeWebApp:
+		return a.verify(ctx, payload, true)
+	case SchemeLogin:
+		return a.verify(ctx, payload, false)
+	default:
+		return nil, serviceauth.NoCredentials()
+	}
+}
+
+func splitScheme(header string) (string, string) {
+	scheme, payload, _ := strings.Cut(strings.TrimSpace(header), " ")
+
+	return strings.ToLower(scheme), strings.TrimSpace(payload)
+}
+
+func (a *Authorizer) verify(ctx context.Context, raw string, webApp bool) (ds.Actor, error) {
+	if raw == "" {
+		return nil, serviceauth.Invalid(serviceauth.ReasonMalformed, errEmptyPayload)
+	}
+
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return nil, serviceauth.Invalid(serviceauth.ReasonMalformed, err)
+	}
+
+	return a.verifyValues(ctx, values, webApp)
+}
+
+func (a *Authorizer) verifyValues(ctx context.Context, values url.Values, webApp bool) (ds.Actor, error) {
+	hash, err := hex.DecodeString(values.Get("hash"))
+	if err != nil || len(hash) != sha256.Size {
+		return nil, serviceauth.Invalid(serviceauth.ReasonMalformed, errNoHash)
+	}
+
+	bot, ok := a.matchBot(dataCheckString(values), hash, webApp)
+	if !ok {
+		return nil, serviceauth.Invalid(serviceauth.ReasonSignature, errHashMismatch)
+	}
+
+	authDate, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil {
+		return nil, serviceauth.Invalid(serviceauth.ReasonMalformed, errBadAuthDate)
+	}
+
+	issued := time.Unix(authDate, 0)
+	now := a.cfg.Now()
+
+	if issued.After(now.Add(clockSkew)) {
+		return nil, serviceauth.Invalid(serviceauth.ReasonNotYetValid, nil)
+	}
+
+	expires := issued.Add(a.cfg.MaxAge)
+	if !now.Before(expires) {
+		return nil, serviceauth.Invalid(serviceauth.ReasonExpired, nil)
+	}
+
+	user, err := parseUser(values, webApp)
+	if err != nil {
+		return nil, serviceauth.Invalid(serviceauth.ReasonMalformed, err)
+	}
+
+	act, err := a.cfg.ActorFunc(ctx, bot, user)
+	if err != nil {
+		return nil, serviceauth.Invalid(serviceauth.ReasonUnknownActor, err)
+	}
+
+	if base, ok := act.(*actor.Actor); ok && (base.ExpiresAt.IsZero() || expires.Before(base.ExpiresAt)) {
+		base.ExpiresAt = expires
+	}
+
+	return act, nil
+}
+
+// matchBot checks hash against every bot without early exit
+func (a *Authorizer) matchBot(data string, hash []byte, webApp bool) (string, bool) {
+	var (
+		name  string
+		found bool
+	)
+
+	for _, bot := range *a.bots.Load() {
+		secret := bot.login
+		if webApp {
+			secret = bot.webApp
+		}
+
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(data))
+
+		if hmac.Equal(mac.Sum(nil), hash) && !found {
+			name, found = bot.name, true
+		}
+	}
+
+	return name, found
+}
+
+// dataCheckString joins all fields but hash as sorted "key=value" lines
+func dataCheckString(values url.Values) string {
+	keys := make([]string, 0, len(values))
+
+	for k := range values {
+		if k != "hash" {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, k+"="+values.Get(k))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func parseUser(values url.Values, webApp bool) (User, error) {
+	var user User
+
+	if webApp {
+		raw := values.Get("user")
+		if raw == "" {
+			return user, errNoUser
+		}
+
+		if err := json.Unmarshal([]byte(raw), &user); err != nil {
+			return user, errors.Wrap(err, "parse user")
+		}
+	} else {
+		id, err := strconv.ParseInt(values.Get("id"), 10, 64)
+		if err != nil {
+			return user, errNoUser
+		}
+
+		user = User{
+			ID:        id,
+			FirstName: values.Get("first_name"),
+			LastName:  values.Get("last_name"),
+			Username:  values.Get("username"),
+			PhotoURL:  values.Get("photo_url"),
+		}
+	}
+
+	if user.ID <= 0 {
+		return user, errNoUser
+	}
+
+	return user, nil
+}
+
+// UserActor is the default ActorFunc, actor ID is Telegram user ID
+func UserActor(_ context.Context, bot string, user User) (ds.Actor, error) {
+	return &actor.Actor{
+		ID: user.ID,
+		Attributes: map[string]string{
+			AttributeBot:      bot,
+			AttributeUsername: user.Username,
+		},
+	}, nil
+}
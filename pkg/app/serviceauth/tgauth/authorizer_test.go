@@ -0,0 +1,125 @@
+package tgauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app/serviceauth"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+)
+
+// sign adds hash to values the way Telegram does
+func sign(values url.Values, token string, webApp bool) string {
+	var secret []byte
+
+	if webApp {
+		mac := hmac.New(sha256.New, []byte("WebAppData"))
+		mac.Write([]byte(token))
+		secret = mac.Sum(nil)
+	} else {
+		sum := sha256.Sum256([]byte(token))
+		secret = sum[:]
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(dataCheckString(values)))
+	values.Set("hash", hex.EncodeToString(mac.Sum(nil)))
+
+	return values.Encode()
+}
+
+func initData(authDate time.Time) url.Values {
+	return url.Values{
+		"query_id":  {"AAHdF6IQAAAAAN0XohDhrOrc"},
+		"user":      {`{"id":279058397,"first_name":"Vlad","username":"vdkfrost","language_code":"ru"}`},
+		"auth_date": {strconv.FormatInt(authDate.Unix(), 10)},
+	}
+}
+
+func TestAuthorizer(t *testing.T) {
+	now := time.Now()
+
+	a, err := New(Config{
+		Bots:   []Bot{{Name: "shop", Token: "111:shop-token"}, {Name: "support", Token: "222:support-token"}},
+		MaxAge: time.Hour,
+		Now:    func() time.Time { return now },
+	})
+	require.NoError(t, err)
+
+	_, err = a.Init(context.Background(), nil, nil)
+	require.NoError(t, err)
+
+	auth := func(header string) (*actor.Actor, error) {
+		r := httptest.NewRequest("GET", "/", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+
+		act, err := a.AuthRest(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return act.(*actor.Actor), nil
+	}
+
+	// web app of the second bot
+	act, err := auth("tma " + sign(initData(now.Add(-time.Minute)), "222:support-token", true))
+	require.NoError(t, err)
+	assert.Equal(t, int64(279058397), act.GetID())
+	assert.Equal(t, "support", act.Attributes[AttributeBot])
+	assert.Equal(t, "vdkfrost", act.Attributes[AttributeUsername])
+	assert.Equal(t, now.Add(-time.Minute).Add(time.Hour).Unix(), act.ExpiresAt.Unix())
+
+	// login widget
+	login := url.Values{"id": {"42"}, "first_name": {"Ann"}, "auth_date": {strconv.FormatInt(now.Unix(), 10)}}
+	act, err = auth("tglogin " + sign(login, "111:shop-token", false))
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), act.GetID())
+	assert.Equal(t, "shop", act.Attributes[AttributeBot])
+
+	fields, err := url.ParseQuery(sign(login, "111:shop-token", false))
+	require.NoError(t, err)
+	_, err = a.AuthenticateLogin(context.Background(), fields)
+	require.NoError(t, err)
+
+	_, err = auth("")
+	require.ErrorIs(t, err, serviceauth.ErrNoCredentials)
+
+	tampered := initData(now)
+	signed := sign(tampered, "111:shop-token", true)
+	tampered, _ = url.ParseQuery(signed)
+	tampered.Set("user", `{"id":1}`)
+
+	tests := []struct {
+		name   string
+		header string
+		reason string
+	}{
+		{name: "unknown bot", header: "tma " + sign(initData(now), "333:other", true), reason: serviceauth.ReasonSignature},
+		{name: "login secret for web app", header: "tma " + sign(initData(now), "111:shop-token", false), reason: serviceauth.ReasonSignature},
+		{name: "tampered", header: "tma " + tampered.Encode(), reason: serviceauth.ReasonSignature},
+		{name: "expired", header: "tma " + sign(initData(now.Add(-2*time.Hour)), "111:shop-token", true), reason: serviceauth.ReasonExpired},
+		{name: "future", header: "tma " + sign(initData(now.Add(time.Hour)), "111:shop-token", true), reason: serviceauth.ReasonNotYetValid},
+		{name: "no hash", header: "tma auth_date=1", reason: serviceauth.ReasonMalformed},
+		{name: "empty", header: "tma  ", reason: serviceauth.ReasonMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth(tt.header)
+			require.Error(t, err)
+			assert.Equal(t, tt.reason, serviceauth.ReasonOf(err))
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package serviceauth
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-faster/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+)
+
+// GRPCRequest returns an HTTP request carrying gRPC metadata as headers, so
+// header based authorizers (JWT, API keys) serve gRPC through AuthRest.
+// Pseudo-headers and binary ("-bin") keys are skipped.
+func GRPCRequest(ctx context.Context, md map[string][]string) *http.Request {
+	header := make(http.Header, len(md))
+
+	for key, values := range md {
+		if strings.HasPrefix(key, ":") || strings.HasSuffix(key, "-bin") {
+			continue
+		}
+
+		for _, v := range values {
+			header.Add(key, v)
+		}
+	}
+
+	return newRequest(ctx, header)
+}
+
+func newRequest(ctx context.Context, header http.Header) *http.Request {
+	r := &http.Request{
+		Method:     http.MethodPost,
+		URL:        &url.URL{Path: "/"},
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2,
+		Header:     header,
+		Body:       http.NoBody,
+	}
+
+	return r.WithContext(ctx)
+}
+
+// AuthGRPC authenticates a gRPC call: natively if a implements ds.GRPCAuthorizer,
+// by AuthRest over GRPCRequest otherwise
+func AuthGRPC(ctx context.Context, a ds.Authorizer, md map[string][]string) (ds.Actor, error) {
+	ctx = WithTransport(ctx, TransportGRPC)
+
+	if g, ok := a.(ds.GRPCAuthorizer); ok {
+		return g.AuthGRPC(ctx, md)
+	}
+
+	return a.AuthRest(GRPCRequest(ctx, md))
+}
+
+// TelegramRequest returns an HTTP request without credentials standing for a
+// Telegram update. Update fields are deliberately not passed as headers, an
+// HTTP client could forge them.
+func TelegramRequest(ctx context.Context) *http.Request {
+	return newRequest(ctx, make(http.Header))
+}
+
+// AuthTelegram authenticates a Telegram update: natively if a implements
+// ds.TelegramAuthorizer, by AuthRest over TelegramRequest otherwise, so
+// credential based authorizers find no credentials and permissive ones
+// (app.UnimplementedAuthorizer) accept the update.
+func AuthTelegram(ctx context.Context, a ds.Authorizer, update ds.TelegramUpdate) (ds.Actor, error) {
+	ctx = WithTransport(ctx, TransportTelegram)
+
+	if t, ok := a.(ds.TelegramAuthorizer); ok {
+		return t.AuthTelegram(ctx, update)
+	}
+
+	return a.AuthRest(TelegramRequest(ctx))
+}
+
+// telegramAuthorizer names TelegramAuthorizer in audit events
+const telegramAuthorizer = "telegram_update"
+
+// TelegramActorFunc maps the sender of an update to an actor
+type TelegramActorFunc func(ctx context.Context, update ds.TelegramUpdate) (ds.Actor, error)
+
+// TelegramAuthorizer adds Telegram updates support to another authorizer
+type TelegramAuthorizer struct {
+	ds.Authorizer
+	actorFunc TelegramActorFunc
+}
+
+var (
+	_ ds.GRPCAuthorizer     = (*TelegramAuthorizer)(nil)
+	_ ds.TelegramAuthorizer = (*TelegramAuthorizer)(nil)
+)
+
+// WithTelegram returns inner authorizer authenticating Telegram updates by fn.
+// Updates without a sender have no credentials.
+func WithTelegram(inner ds.Authorizer, fn TelegramActorFunc) *TelegramAuthorizer {
+	return &TelegramAuthorizer{Authorizer: inner, actorFunc: fn}
+}
+
+// Init initializes the inner authorizer
+func (a *TelegramAuthorizer) Init(ctx context.Context, drvs []ds.Runnable, m *prometheus.Registry) (ds.Authorizer, error) {
+	inner, err := a.Authorizer.Init(ctx, drvs, m)
+	if err != nil {
+		return nil, err
+	}
+
+	a.Authorizer = inner
+
+	return a, nil
+}
+
+// AuthGRPC delegates to the inner authorizer
+func (a *TelegramAuthorizer) AuthGRPC(ctx context.Context, md map[string][]string) (ds.Actor, error) {
+	return AuthGRPC(ctx, a.Authorizer, md)
+}
+
+func (a *TelegramAuthorizer) AuthTelegram(ctx context.Context, update ds.TelegramUpdate) (ds.Actor, error) {
+	if update.UserID == 0 {
+		return nil, NoCredentials()
+	}
+
+	act, err := a.actorFunc(ctx, update)
+	if err != nil {
+		var authErr *Error
+		if !errors.As(err, &authErr) {
+			err = Invalid(ReasonUnknownActor, err)
+		}
+	}
+
+	AuditContext(WithTransport(ctx, TransportTelegram), telegramAuthorizer, act, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return act, nil
+}
+
+// HasCredentials delegates to the inner authorizer
+func (a *TelegramAuthorizer) HasCredentials(r *http.Request) bool {
+	return HasCredentials(a.Authorizer, r)
+}
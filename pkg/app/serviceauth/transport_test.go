@@ -0,0 +1,87 @@
+package serviceauth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/go-faster/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/app"
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+)
+
+// tokenAuthorizer accepts "X-Token: good" only
+type tokenAuthorizer struct {
+	app.UnimplementedAuthorizer
+}
+
+func (a *tokenAuthorizer) AuthRest(r *http.Request) (ds.Actor, error) {
+	switch r.Header.Get("X-Token") {
+	case "":
+		return nil, NoCredentials()
+	case "good":
+		return &actor.Actor{ID: 1}, nil
+	default:
+		return nil, Invalid(ReasonUnknownKey, nil)
+	}
+}
+
+func TestAuthGRPC(t *testing.T) {
+	ctx := context.Background()
+	a := &tokenAuthorizer{}
+
+	r := GRPCRequest(ctx, map[string][]string{":authority": {"host"}, "x-token": {"good"}, "trace-bin": {"\x00"}})
+	assert.Equal(t, "good", r.Header.Get("X-Token"))
+	assert.Empty(t, r.Header.Get("Trace-Bin"))
+	assert.Len(t, r.Header, 1)
+
+	act, err := AuthGRPC(ctx, a, map[string][]string{"x-token": {"good"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), act.GetID())
+
+	_, err = AuthGRPC(ctx, a, map[string][]string{"x-token": {"bad"}})
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// permissive defaults of UnimplementedAuthorizer are kept
+	act, err = AuthGRPC(ctx, &app.UnimplementedAuthorizer{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, actor.KindAnonymous, actor.KindOf(act))
+}
+
+func TestAuthTelegram(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := AuthTelegram(ctx, &tokenAuthorizer{}, ds.TelegramUpdate{UserID: 5})
+	require.ErrorIs(t, err, ErrNoCredentials)
+
+	act, err := AuthTelegram(ctx, &app.UnimplementedAuthorizer{}, ds.TelegramUpdate{UserID: 5})
+	require.NoError(t, err)
+	assert.Equal(t, actor.KindAnonymous, actor.KindOf(act))
+
+	a := WithTelegram(&tokenAuthorizer{}, func(_ context.Context, update ds.TelegramUpdate) (ds.Actor, error) {
+		if update.UserID != 5 {
+			return nil, errors.New("unknown telegram user")
+		}
+
+		return &actor.Actor{ID: 50}, nil
+	})
+
+	act, err = AuthTelegram(ctx, a, ds.TelegramUpdate{UserID: 5})
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), act.GetID())
+
+	_, err = a.AuthTelegram(ctx, ds.TelegramUpdate{UserID: 6})
+	assert.Equal(t, ReasonUnknownActor, ReasonOf(err))
+
+	_, err = a.AuthTelegram(ctx, ds.TelegramUpdate{ChatID: 1})
+	require.ErrorIs(t, err, ErrNoCredentials)
+
+	// HTTP and gRPC are still served by the inner authorizer
+	act, err = AuthGRPC(ctx, a, map[string][]string{"x-token": {"good"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), act.GetID())
+}
@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
+)
+
+// TracerName of spans started by Middleware
+const TracerName = "github.com/Educentr/go-project-starter-runtime/pkg/app/tracing"
+
+// RoutePattern returns the pattern of the ServeMux route handling r. It is
+// empty if the middleware wraps the mux, pass MuxRoute to Middleware then.
+func RoutePattern(r *http.Request) string {
+	return r.Pattern
+}
+
+// MuxRoute returns the pattern mux would route r to
+func MuxRoute(mux *http.ServeMux) func(*http.Request) string {
+	return func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		return pattern
+	}
+}
+
+// Middleware starts a server span continuing the incoming trace context and
+// adds TraceID and SpanID to the logger context. route names the span and is
+// matched by sampling rules, RoutePattern if nil.
+func Middleware(route func(*http.Request) string) func(http.Handler) http.Handler {
+	if route == nil {
+		route = RoutePattern
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+			name := r.Method
+			attrs := []attribute.KeyValue{semconv.HTTPRequestMethodKey.String(r.Method)}
+
+			if pattern := route(r); pattern != "" {
+				name = pattern
+				attrs = append(attrs, semconv.HTTPRoute(pattern))
+			}
+
+			ctx, span := otel.Tracer(TracerName).Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(attrs...),
+			)
+			defer span.End()
+
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r.WithContext(reqctx.SetSpanFields(ctx)))
+
+			span.SetAttributes(semconv.HTTPResponseStatusCode(sw.status))
+
+			if sw.status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(sw.status))
+			}
+		})
+	}
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the original writer
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
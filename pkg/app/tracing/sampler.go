@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"strings"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// RouteAttribute is matched by route rules, the span name is used if it is absent
+const RouteAttribute = semconv.HTTPRouteKey
+
+// RouteRule sets the sampling ratio of root spans of a route
+type RouteRule struct {
+	// Route is the exact route or a prefix ending with "*"
+	Route string `json:"route" yaml:"route"`
+	// Ratio of sampled traces from 0 to 1
+	Ratio float64 `json:"ratio" yaml:"ratio"`
+}
+
+func (r RouteRule) match(route string) bool {
+	if prefix, ok := strings.CutSuffix(r.Route, "*"); ok {
+		return strings.HasPrefix(route, prefix)
+	}
+
+	return r.Route == route
+}
+
+type routeSampler struct {
+	rules    []RouteRule
+	samplers []sdktrace.Sampler
+	fallback sdktrace.Sampler
+}
+
+// NewSampler returns a parent-based sampler: children follow the decision of
+// the parent, root spans are sampled by the first matching rule or by ratio
+func NewSampler(ratio float64, rules []RouteRule) sdktrace.Sampler {
+	s := &routeSampler{
+		rules:    rules,
+		samplers: make([]sdktrace.Sampler, 0, len(rules)),
+		fallback: sdktrace.TraceIDRatioBased(ratio),
+	}
+
+	for _, rule := range rules {
+		s.samplers = append(s.samplers, sdktrace.TraceIDRatioBased(rule.Ratio))
+	}
+
+	return sdktrace.ParentBased(s)
+}
+
+// ShouldSample implements sdktrace.Sampler
+func (s *routeSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	route := p.Name
+
+	for _, attr := range p.Attributes {
+		if attr.Key == RouteAttribute {
+			route = attr.Value.AsString()
+			break
+		}
+	}
+
+	for i, rule := range s.rules {
+		if rule.match(route) {
+			return s.samplers[i].ShouldSample(p)
+		}
+	}
+
+	return s.fallback.ShouldSample(p)
+}
+
+// Description implements sdktrace.Sampler
+func (s *routeSampler) Description() string {
+	return "RouteSampler{" + s.fallback.Description() + "}"
+}
+
+var _ sdktrace.Sampler = (*routeSampler)(nil)
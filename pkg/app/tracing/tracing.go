@@ -0,0 +1,210 @@
+// Package tracing configures the OpenTelemetry TracerProvider of the application.
+//
+// Spans are exported by OTLP over HTTP or written as JSON to stdout or a file
+// for local use. Root spans are sampled by ratio with per-route overrides,
+// child spans follow their parent. App.InitTracing sets the provider up and
+// App shuts it down after drivers during graceful stop.
+package tracing
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/go-faster/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+)
+
+// Exporter of spans
+type Exporter string
+
+const (
+	// ExporterOTLP sends spans to an OTLP/HTTP collector
+	ExporterOTLP Exporter = "otlp"
+	// ExporterStdout writes spans as JSON to stdout
+	ExporterStdout Exporter = "stdout"
+	// ExporterFile writes spans as JSON to Config.File
+	ExporterFile Exporter = "file"
+	// ExporterNone disables exporting, spans are still created for propagation
+	ExporterNone Exporter = "none"
+)
+
+const fileMode = 0o644
+
+// Config of tracing
+type Config struct {
+	// Exporter is ExporterOTLP by default
+	Exporter Exporter `json:"exporter" yaml:"exporter"`
+
+	// Endpoint of the OTLP collector (host:port), OTEL_EXPORTER_OTLP_ENDPOINT
+	// or localhost:4318 by default
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	// Insecure disables TLS of the OTLP exporter
+	Insecure bool `json:"insecure" yaml:"insecure"`
+	// Headers sent with OTLP requests, e.g. authorization
+	Headers map[string]string `json:"headers" yaml:"headers"`
+
+	// File of ExporterFile, appended to
+	File string `json:"file" yaml:"file"`
+
+	// Ratio of sampled root spans, 1 if <= 0. Use ExporterNone to disable tracing.
+	Ratio float64 `json:"ratio" yaml:"ratio"`
+	// Routes override Ratio, the first matching rule is applied
+	Routes []RouteRule `json:"routes" yaml:"routes"`
+}
+
+// Provider is the TracerProvider with resources of its exporter
+type Provider struct {
+	*sdktrace.TracerProvider
+
+	closer io.Closer
+}
+
+// Shutdown flushes pending spans and stops the provider
+func (p *Provider) Shutdown(ctx context.Context) error {
+	err := p.TracerProvider.Shutdown(ctx)
+	if err != nil {
+		err = errors.Wrap(err, "can't shutdown tracer provider")
+	}
+
+	if p.closer != nil {
+		if closeErr := p.closer.Close(); closeErr != nil {
+			err = errors.Join(err, errors.Wrap(closeErr, "can't close trace file"))
+		}
+	}
+
+	return err
+}
+
+// InitTracing creates the provider and makes it global together with W3C
+// trace context and baggage propagators
+func InitTracing(ctx context.Context, serviceName string, info *ds.AppInfo, cfg Config) (*Provider, error) {
+	res, err := newResource(serviceName, info)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Ratio <= 0 {
+		cfg.Ratio = 1
+	}
+
+	p := &Provider{}
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(NewSampler(cfg.Ratio, cfg.Routes)),
+	}
+
+	exp, err := p.newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if exp != nil {
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	}
+
+	p.TracerProvider = sdktrace.NewTracerProvider(opts...)
+
+	otel.SetTracerProvider(p.TracerProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return p, nil
+}
+
+func (p *Provider) newExporter(ctx context.Context, cfg Config) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case ExporterOTLP, "":
+		var opts []otlptracehttp.Option
+
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+		}
+
+		exp, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't create OTLP trace exporter")
+		}
+
+		return exp, nil
+	case ExporterStdout:
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, errors.Wrap(err, "can't create stdout trace exporter")
+		}
+
+		return exp, nil
+	case ExporterFile:
+		if cfg.File == "" {
+			return nil, errors.New("trace file is not set")
+		}
+
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, fileMode)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't open trace file")
+		}
+
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			_ = f.Close()
+			return nil, errors.Wrap(err, "can't create file trace exporter")
+		}
+
+		p.closer = f
+
+		return exp, nil
+	case ExporterNone:
+		return nil, nil
+	default:
+		return nil, errors.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+}
+
+func newResource(serviceName string, info *ds.AppInfo) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{semconv.ServiceName(serviceName)}
+
+	if info != nil {
+		attrs = append(attrs,
+			semconv.ServiceVersion(info.Version),
+			attribute.String("app.name", info.AppName),
+			attribute.String("app.build_time", info.BuildTime),
+			attribute.Bool("app.vcs_modified", info.VCSModified),
+			semconv.VCSRefHeadRevision(info.BuildCommit),
+		)
+
+		if info.Hostname != "" {
+			attrs = append(attrs, semconv.HostName(info.Hostname), semconv.ServiceInstanceID(info.Hostname))
+		}
+
+		if info.GoVersion != "" {
+			attrs = append(attrs, semconv.ProcessRuntimeVersion(info.GoVersion))
+		}
+
+		if info.GOOS != "" {
+			attrs = append(attrs, semconv.OSTypeKey.String(info.GOOS), semconv.HostArchKey.String(info.GOARCH))
+		}
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, attrs...))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't create trace resource")
+	}
+
+	return res, nil
+}
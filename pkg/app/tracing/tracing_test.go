@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+)
+
+func TestNewSampler(t *testing.T) {
+	s := NewSampler(1, []RouteRule{
+		{Route: "GET /health", Ratio: 0},
+		{Route: "/internal/*", Ratio: 0},
+	})
+
+	sample := func(ctx context.Context, name string, route string) bool {
+		p := sdktrace.SamplingParameters{
+			ParentContext: ctx,
+			TraceID:       trace.TraceID{1},
+			Name:          name,
+		}
+
+		if route != "" {
+			p.Attributes = append(p.Attributes, semconv.HTTPRoute(route))
+		}
+
+		return s.ShouldSample(p).Decision == sdktrace.RecordAndSample
+	}
+
+	ctx := context.Background()
+
+	assert.True(t, sample(ctx, "GET /orders", ""))
+	assert.False(t, sample(ctx, "GET /health", ""))
+	assert.False(t, sample(ctx, "span", "/internal/debug"))
+	assert.True(t, sample(ctx, "/internal/debug", "/orders"), "route attribute wins over the name")
+
+	parent := trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{2},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}))
+	assert.True(t, sample(parent, "GET /health", ""), "children follow the parent")
+}
+
+func TestInitTracing_File(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "spans.json")
+
+	p, err := InitTracing(context.Background(), "svc-api", &ds.AppInfo{Version: "1.2.3", Hostname: "host-1"}, Config{
+		Exporter: ExporterFile,
+		File:     file,
+	})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		sc := trace.SpanContextFromContext(r.Context())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID().String())
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	w := httptest.NewRecorder()
+	Middleware(MuxRoute(mux))(mux).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusTeapot, w.Code)
+
+	require.NoError(t, p.Shutdown(context.Background()))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Name":"GET /orders/{id}"`)
+	assert.Contains(t, string(data), "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Contains(t, string(data), `"Value":"svc-api"`)
+	assert.Contains(t, string(data), `"Value":"1.2.3"`)
+}
+
+func TestInitTracing_UnknownExporter(t *testing.T) {
+	_, err := InitTracing(context.Background(), "svc", nil, Config{Exporter: "zipkin"})
+	require.Error(t, err)
+}
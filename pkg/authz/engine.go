@@ -0,0 +1,295 @@
+// Package authz checks permissions of the actor stored by reqctx.SetActor
+// against RBAC policy.
+//
+// Permissions are granted by roles of the actor only (see Policy). Scopes
+// never grant anything, they narrow what roles grant: a permission of an actor
+// with scopes must also match a scope. Actors without roles have no permissions. Actors expose roles,
+// scopes and attributes by implementing RoleProvider, ScopeProvider and
+// AttributeProvider, actor.Actor implements all of them.
+//
+//	authz.SetDefault(engine)
+//	...
+//	if err := authz.Require(ctx, "orders:write"); err != nil {
+//	    return authz.StatusCode(err), err
+//	}
+package authz
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/Educentr/go-onlineconf/pkg/onlineconf"
+	"github.com/go-faster/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
+)
+
+const (
+	resultAllow = "allow"
+	resultDeny  = "deny"
+)
+
+// ImpersonatePermission prefixes permissions checked by ImpersonationPolicy,
+// e.g. "impersonate:user" allows acting on behalf of users
+const ImpersonatePermission = "impersonate"
+
+// RoleProvider is implemented by actors with roles
+type RoleProvider interface {
+	GetRoles() []string
+}
+
+// ScopeProvider is implemented by actors with scopes
+type ScopeProvider interface {
+	GetScopes() []string
+}
+
+// AttributeProvider is implemented by actors with attributes
+type AttributeProvider interface {
+	GetAttribute(key string) (string, bool)
+}
+
+// Engine makes authorization decisions. The policy can be replaced at runtime.
+type Engine struct {
+	policy    atomic.Pointer[compiled]
+	decisions *prometheus.CounterVec
+}
+
+// New creates the engine with policy p
+func New(p *Policy) (*Engine, error) {
+	e := &Engine{
+		decisions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Subsystem: "authz",
+				Name:      "decisions_total",
+				Help:      "Total number of authorization decisions by permission, result, denial reason and actor kind",
+			},
+			[]string{"permission", "result", "reason", "kind"},
+		),
+	}
+
+	if err := e.SetPolicy(p); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// Collectors returns metrics of the engine to register
+func (e *Engine) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{e.decisions}
+}
+
+// SetPolicy validates p and atomically replaces the policy, on error the previous one is kept
+func (e *Engine) SetPolicy(p *Policy) error {
+	c, err := p.compile()
+	if err != nil {
+		return err
+	}
+
+	e.policy.Store(&c)
+
+	return nil
+}
+
+// Subscribe loads the policy from onlineconf path and reloads it on updates.
+// The value is a YAML string or a JSON structure.
+func (e *Engine) Subscribe(ctx context.Context, path string) error {
+	reload := func() error {
+		p, err := policyFromOnlineconf(ctx, path)
+		if err != nil {
+			return err
+		}
+
+		return e.SetPolicy(p)
+	}
+
+	if err := reload(); err != nil {
+		return err
+	}
+
+	if err := onlineconf.RegisterSubscription(ctx, onlineconf.DefaultModule, []string{path}, reload); err != nil {
+		return errors.Wrap(err, "can't subscribe to policy update")
+	}
+
+	return nil
+}
+
+func policyFromOnlineconf(ctx context.Context, path string) (*Policy, error) {
+	str, found, err := onlineconf.GetStringIfExists(ctx, path)
+	if err == nil {
+		if !found {
+			return nil, errors.Errorf("policy %s not found", path)
+		}
+
+		return ParsePolicy([]byte(str))
+	}
+
+	p := &Policy{}
+
+	if _, err = onlineconf.GetStruct(ctx, path, p); err != nil {
+		return nil, errors.Wrapf(err, "can't read policy from %s", path)
+	}
+
+	return p, nil
+}
+
+// Require checks that the actor of ctx has the permission. Impersonated
+// actors are checked by their own permissions.
+func (e *Engine) Require(ctx context.Context, perm string) error {
+	act, err := reqctx.GetActor(ctx)
+	if err != nil {
+		return e.deny(perm, nil, ReasonUnauthenticated)
+	}
+
+	err = e.Check(act, perm)
+
+	var denied *DeniedError
+	if reqctx.IsImpersonated(ctx) && errors.As(err, &denied) {
+		if real, rErr := reqctx.GetRealActor(ctx); rErr == nil {
+			denied.RealActorID, denied.RealActorSID = actorIDs(real)
+		}
+	}
+
+	return err
+}
+
+// Check checks that act has the permission, nil and anonymous actors are unauthenticated
+func (e *Engine) Check(act ds.Actor, perm string) error {
+	if act == nil {
+		return e.deny(perm, nil, ReasonUnauthenticated)
+	}
+
+	if actor.KindOf(act) == actor.KindAnonymous {
+		return e.deny(perm, act, ReasonUnauthenticated)
+	}
+
+	if reason := e.decide(act, perm); reason != "" {
+		return e.deny(perm, act, reason)
+	}
+
+	e.decisions.WithLabelValues(perm, resultAllow, "", string(actor.KindOf(act))).Inc()
+
+	return nil
+}
+
+// ImpersonationPolicy returns the policy for reqctx.SetImpersonationPolicy:
+// the real actor needs ImpersonatePermission + ":" + kind of the effective actor
+func (e *Engine) ImpersonationPolicy() reqctx.ImpersonationPolicy {
+	return func(_ context.Context, real, effective ds.Actor) error {
+		return e.Check(real, ImpersonatePermission+":"+string(actor.KindOf(effective)))
+	}
+}
+
+// decide returns denial reason, "" if the permission is granted
+func (e *Engine) decide(act ds.Actor, perm string) string {
+	var roles, scopes []string
+
+	if p, ok := act.(RoleProvider); ok {
+		roles = p.GetRoles()
+	}
+
+	if p, ok := act.(ScopeProvider); ok {
+		scopes = p.GetScopes()
+	}
+
+	if len(roles) == 0 {
+		return ReasonNotGranted
+	}
+
+	if len(scopes) > 0 && !matchAny(scopes, perm) {
+		return ReasonScope
+	}
+
+	policy := e.policy.Load()
+	attrs, _ := act.(AttributeProvider)
+
+	for _, role := range roles {
+		for _, g := range (*policy)[role] {
+			if Match(g.pattern, perm) && conditionHolds(g.when, attrs) {
+				return ""
+			}
+		}
+	}
+
+	return ReasonNotGranted
+}
+
+func (e *Engine) deny(perm string, act ds.Actor, reason string) error {
+	e.decisions.WithLabelValues(perm, resultDeny, reason, string(actor.KindOf(act))).Inc()
+
+	denied := &DeniedError{Permission: perm, Reason: reason}
+	if act != nil {
+		denied.ActorID, denied.ActorSID = actorIDs(act)
+	}
+
+	return denied
+}
+
+// actorIDs returns numeric and string IDs of act
+func actorIDs(act ds.Actor) (int64, string) {
+	if p, ok := act.(actor.StringIDProvider); ok {
+		return act.GetID(), p.GetStringID()
+	}
+
+	return act.GetID(), ""
+}
+
+func matchAny(patterns []string, perm string) bool {
+	for _, pattern := range patterns {
+		if Match(pattern, perm) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func conditionHolds(when map[string]string, attrs AttributeProvider) bool {
+	if len(when) == 0 {
+		return true
+	}
+
+	if attrs == nil {
+		return false
+	}
+
+	for key, want := range when {
+		if got, ok := attrs.GetAttribute(key); !ok || got != want {
+			return false
+		}
+	}
+
+	return true
+}
+
+var defaultEngine atomic.Pointer[Engine]
+
+// SetDefault sets the engine used by package level Require and Check.
+// This should be called once during application initialization.
+func SetDefault(e *Engine) {
+	defaultEngine.Store(e)
+}
+
+// Require checks the permission with the default engine. Without the
+// default engine every permission is denied.
+func Require(ctx context.Context, perm string) error {
+	e := defaultEngine.Load()
+	if e == nil {
+		return &DeniedError{Permission: perm, Reason: ReasonNoPolicy}
+	}
+
+	return e.Require(ctx, perm)
+}
+
+// Check checks the permission of act with the default engine
+func Check(act ds.Actor, perm string) error {
+	e := defaultEngine.Load()
+	if e == nil {
+		return &DeniedError{Permission: perm, Reason: ReasonNoPolicy}
+	}
+
+	return e.Check(act, perm)
+}
@@ -0,0 +1,199 @@
+package authz
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/Educentr/go-onlineconf/pkg/onlineconf"
+	"github.com/colinmarc/cdb"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
+)
+
+const testPolicy = `
+roles:
+  viewer:
+    permissions: ["orders:read"]
+  manager:
+    inherits: [viewer]
+    permissions: ["orders:*"]
+  support:
+    permissions: ["tickets:write"]
+    when: {department: support}
+  admin:
+    permissions: ["*"]
+`
+
+func newEngine(t *testing.T) *Engine {
+	t.Helper()
+
+	p, err := ParsePolicy([]byte(testPolicy))
+	require.NoError(t, err)
+
+	e, err := New(p)
+	require.NoError(t, err)
+
+	return e
+}
+
+func TestEngine_Check(t *testing.T) {
+	e := newEngine(t)
+
+	tests := []struct {
+		name   string
+		actor  *actor.Actor
+		perm   string
+		reason string
+	}{
+		{name: "role", actor: &actor.Actor{ID: 1, Roles: []string{"viewer"}}, perm: "orders:read"},
+		{name: "not granted", actor: &actor.Actor{ID: 1, Roles: []string{"viewer"}}, perm: "orders:write", reason: ReasonNotGranted},
+		{name: "prefix wildcard", actor: &actor.Actor{ID: 1, Roles: []string{"manager"}}, perm: "orders:write"},
+		{name: "inherited", actor: &actor.Actor{ID: 1, Roles: []string{"manager"}}, perm: "orders:read"},
+		{name: "prefix is not substring", actor: &actor.Actor{ID: 1, Roles: []string{"manager"}}, perm: "ordersx:read", reason: ReasonNotGranted},
+		{name: "admin", actor: &actor.Actor{ID: 1, Roles: []string{"admin"}}, perm: "anything"},
+		{name: "unknown role", actor: &actor.Actor{ID: 1, Roles: []string{"root"}}, perm: "orders:read", reason: ReasonNotGranted},
+		{
+			name:  "condition holds",
+			actor: &actor.Actor{ID: 1, Roles: []string{"support"}, Attributes: map[string]string{"department": "support"}},
+			perm:  "tickets:write",
+		},
+		{
+			name:   "condition fails",
+			actor:  &actor.Actor{ID: 1, Roles: []string{"support"}, Attributes: map[string]string{"department": "sales"}},
+			perm:   "tickets:write",
+			reason: ReasonNotGranted,
+		},
+		{name: "scope only", actor: &actor.Actor{ID: 1, Scopes: []string{"orders:read"}}, perm: "orders:read", reason: ReasonNotGranted},
+		{name: "wildcard scope only", actor: &actor.Actor{ID: 1, Scopes: []string{"*"}}, perm: ImpersonatePermission + ":user", reason: ReasonNotGranted},
+		{name: "scope within role", actor: &actor.Actor{ID: 1, Roles: []string{"manager"}, Scopes: []string{"orders:read"}}, perm: "orders:read"},
+		{name: "scope restricts role", actor: &actor.Actor{ID: 1, Roles: []string{"admin"}, Scopes: []string{"orders:read"}}, perm: "orders:write", reason: ReasonScope},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := e.Check(tt.actor, tt.perm)
+			if tt.reason == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			var denied *DeniedError
+			require.ErrorAs(t, err, &denied)
+			assert.Equal(t, tt.reason, denied.Reason)
+			assert.Equal(t, http.StatusForbidden, StatusCode(err))
+		})
+	}
+
+	assert.InDelta(t, 1, testutil.ToFloat64(e.decisions.WithLabelValues("orders:write", resultDeny, ReasonScope, string(actor.KindUser))), 0)
+}
+
+func TestEngine_CheckAnonymous(t *testing.T) {
+	e := newEngine(t)
+
+	for _, act := range []ds.Actor{nil, &actor.Actor{Kind: actor.KindAnonymous, Roles: []string{"admin"}}} {
+		err := e.Check(act, "orders:read")
+
+		var denied *DeniedError
+		require.ErrorAs(t, err, &denied)
+		assert.Equal(t, ReasonUnauthenticated, denied.Reason)
+		assert.Equal(t, http.StatusUnauthorized, StatusCode(err))
+	}
+}
+
+func TestRequire(t *testing.T) {
+	ctx := context.Background()
+
+	err := Require(ctx, "orders:read")
+	require.ErrorIs(t, err, ErrForbidden)
+
+	SetDefault(newEngine(t))
+	t.Cleanup(func() { SetDefault(nil) })
+
+	err = Require(ctx, "orders:read")
+	require.ErrorIs(t, err, ErrUnauthenticated)
+	assert.Equal(t, http.StatusUnauthorized, StatusCode(err))
+
+	ctx, err = reqctx.SetActor(ctx, &actor.Actor{ID: 5, Roles: []string{"viewer"}})
+	require.NoError(t, err)
+
+	require.NoError(t, Require(ctx, "orders:read"))
+	require.ErrorIs(t, Require(ctx, "orders:write"), ErrForbidden)
+}
+
+func TestPolicy_Validation(t *testing.T) {
+	_, err := New(&Policy{Roles: map[string]Role{"a": {Inherits: []string{"b"}}, "b": {Inherits: []string{"a"}}}})
+	require.ErrorIs(t, err, errRoleCycle)
+
+	_, err = New(&Policy{Roles: map[string]Role{"a": {Inherits: []string{"missing"}}}})
+	require.ErrorIs(t, err, errUnknownRole)
+}
+
+func TestEngine_Subscribe(t *testing.T) {
+	dir := t.TempDir()
+
+	writer, err := cdb.Create(filepath.Join(dir, onlineconf.DefaultModule+".cdb"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Put([]byte("/test/policy/yaml"), []byte("s"+testPolicy)))
+	require.NoError(t, writer.Put([]byte("/test/policy/json"), []byte(`j{"roles":{"viewer":{"permissions":["orders:write"]}}}`)))
+	require.NoError(t, writer.Close())
+
+	ctx, err := onlineconf.Initialize(context.Background(), onlineconf.WithConfigDir(dir))
+	require.NoError(t, err)
+
+	e, err := New(&Policy{})
+	require.NoError(t, err)
+
+	viewer := &actor.Actor{ID: 1, Roles: []string{"viewer"}}
+
+	require.NoError(t, e.Subscribe(ctx, "/test/policy/yaml"))
+	require.NoError(t, e.Check(viewer, "orders:read"))
+
+	require.NoError(t, e.Subscribe(ctx, "/test/policy/json"))
+	require.NoError(t, e.Check(viewer, "orders:write"))
+	require.Error(t, e.Check(viewer, "orders:read"))
+
+	require.Error(t, e.Subscribe(ctx, "/test/policy/missing"))
+}
+
+func TestEngine_ImpersonationPolicy(t *testing.T) {
+	e := newEngine(t)
+
+	reqctx.SetImpersonationPolicy(e.ImpersonationPolicy())
+	t.Cleanup(func() { reqctx.SetImpersonationPolicy(nil) })
+
+	admin := &actor.Actor{ID: 1, Roles: []string{"admin"}}
+	viewer := &actor.Actor{ID: 2, Roles: []string{"viewer"}}
+	customer := &actor.Actor{ID: 3}
+
+	_, err := reqctx.Impersonate(context.Background(), viewer, customer)
+	require.ErrorIs(t, err, reqctx.ErrImpersonationDenied)
+
+	ctx, err := reqctx.Impersonate(context.Background(), admin, customer)
+	require.NoError(t, err)
+
+	// permissions of the effective actor apply
+	err = e.Require(ctx, "orders:read")
+
+	var denied *DeniedError
+	require.ErrorAs(t, err, &denied)
+	assert.Equal(t, int64(3), denied.ActorID)
+	assert.Equal(t, int64(1), denied.RealActorID)
+
+	// actors identified by string IDs
+	service := &actor.Actor{Kind: actor.KindService, StringID: "crm", Roles: []string{"admin"}}
+
+	ctx, err = reqctx.Impersonate(context.Background(), service, customer)
+	require.NoError(t, err)
+
+	err = e.Require(ctx, "orders:read")
+	require.ErrorAs(t, err, &denied)
+	assert.Equal(t, "crm", denied.RealActorSID)
+	assert.Contains(t, err.Error(), `impersonated by "crm"`)
+}
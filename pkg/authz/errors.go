@@ -0,0 +1,79 @@
+package authz
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-faster/errors"
+)
+
+// Denial reasons, used as metric label values
+const (
+	ReasonUnauthenticated = "unauthenticated"
+	ReasonNoPolicy        = "no_policy"
+	ReasonNotGranted      = "not_granted"
+	ReasonScope           = "scope"
+)
+
+var (
+	// ErrUnauthenticated means there is no actor in the context, transports map it to 401
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrForbidden means the actor lacks the permission, transports map it to 403
+	ErrForbidden = errors.New("forbidden")
+)
+
+// DeniedError is a denied authorization decision
+type DeniedError struct {
+	Permission string
+	// ActorID and ActorSID are empty for unauthenticated requests
+	ActorID  int64
+	ActorSID string
+	// RealActorID and RealActorSID are the actor acting on behalf of the actor,
+	// empty without impersonation
+	RealActorID  int64
+	RealActorSID string
+	Reason       string
+}
+
+func (e *DeniedError) Error() string {
+	msg := "permission " + strconv.Quote(e.Permission) + " denied for actor " + actorRef(e.ActorID, e.ActorSID)
+
+	if e.RealActorID != 0 || e.RealActorSID != "" {
+		msg += " impersonated by " + actorRef(e.RealActorID, e.RealActorSID)
+	}
+
+	return msg + ": " + e.Reason
+}
+
+// actorRef formats the numeric ID, or the quoted string ID of actors without one
+func actorRef(id int64, sid string) string {
+	if id == 0 && sid != "" {
+		return strconv.Quote(sid)
+	}
+
+	return strconv.FormatInt(id, 10)
+}
+
+// Is matches ErrUnauthenticated or ErrForbidden
+func (e *DeniedError) Is(target error) bool {
+	if e.Reason == ReasonUnauthenticated {
+		return target == ErrUnauthenticated
+	}
+
+	return target == ErrForbidden
+}
+
+// StatusCode maps an error of Require to HTTP status: 401 for ErrUnauthenticated,
+// 403 for ErrForbidden, 500 for other errors and 200 for nil
+func StatusCode(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrUnauthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
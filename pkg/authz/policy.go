@@ -0,0 +1,169 @@
+package authz
+
+import (
+	"os"
+	"strings"
+
+	"github.com/go-faster/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Wildcard grants every permission, "orders:*" grants every permission with the "orders:" prefix
+const Wildcard = "*"
+
+var (
+	errUnknownRole  = errors.New("unknown inherited role")
+	errRoleCycle    = errors.New("role inheritance cycle")
+	errEmptyPattern = errors.New("empty permission")
+)
+
+// Policy is a set of RBAC rules. In YAML:
+//
+//	roles:
+//	  viewer:
+//	    permissions: ["orders:read"]
+//	  manager:
+//	    inherits: [viewer]
+//	    permissions: ["orders:*"]
+//	  support:
+//	    permissions: ["tickets:write"]
+//	    when: {department: support}
+//	  admin:
+//	    permissions: ["*"]
+type Policy struct {
+	Roles map[string]Role `yaml:"roles" json:"roles"`
+}
+
+// Role grants permissions to actors having it
+type Role struct {
+	Permissions []string `yaml:"permissions" json:"permissions"`
+	// Inherits permissions of other roles, conditions of both roles apply
+	Inherits []string `yaml:"inherits" json:"inherits"`
+	// When restricts the role to actors with all the attribute values
+	When map[string]string `yaml:"when" json:"when"`
+}
+
+// ParsePolicy parses YAML or JSON policy
+func ParsePolicy(data []byte) (*Policy, error) {
+	p := &Policy{}
+
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, errors.Wrap(err, "parse policy")
+	}
+
+	return p, nil
+}
+
+// LoadFile reads the policy from a YAML or JSON file
+func LoadFile(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read policy")
+	}
+
+	return ParsePolicy(data)
+}
+
+// grant is a permission pattern with the condition of the role it comes from
+type grant struct {
+	pattern string
+	when    map[string]string
+}
+
+// compiled policy with inheritance resolved
+type compiled map[string][]grant
+
+func (p *Policy) compile() (compiled, error) {
+	c := make(compiled, len(p.Roles))
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+
+	state := make(map[string]int, len(p.Roles))
+
+	var visit func(name string) error
+
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return errors.Wrapf(errRoleCycle, "%q", name)
+		case done:
+			return nil
+		}
+
+		state[name] = visiting
+		role := p.Roles[name]
+
+		grants := make([]grant, 0, len(role.Permissions))
+
+		for _, perm := range role.Permissions {
+			if perm == "" {
+				return errors.Wrapf(errEmptyPattern, "role %q", name)
+			}
+
+			grants = append(grants, grant{pattern: perm, when: role.When})
+		}
+
+		for _, parent := range role.Inherits {
+			if _, ok := p.Roles[parent]; !ok {
+				return errors.Wrapf(errUnknownRole, "%q in role %q", parent, name)
+			}
+
+			if err := visit(parent); err != nil {
+				return err
+			}
+
+			for _, g := range c[parent] {
+				grants = append(grants, grant{pattern: g.pattern, when: mergeWhen(role.When, g.when)})
+			}
+		}
+
+		c[name] = grants
+		state[name] = done
+
+		return nil
+	}
+
+	for name := range p.Roles {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func mergeWhen(a, b map[string]string) map[string]string {
+	if len(a) == 0 {
+		return b
+	}
+
+	if len(b) == 0 {
+		return a
+	}
+
+	merged := make(map[string]string, len(a)+len(b))
+
+	for k, v := range b {
+		merged[k] = v
+	}
+
+	for k, v := range a {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// Match reports whether permission pattern grants perm
+func Match(pattern, perm string) bool {
+	if pattern == Wildcard || pattern == perm {
+		return true
+	}
+
+	prefix, ok := strings.CutSuffix(pattern, Wildcard)
+
+	return ok && strings.HasSuffix(prefix, ":") && strings.HasPrefix(perm, prefix)
+}
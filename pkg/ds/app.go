@@ -29,6 +29,27 @@ type Authorizer interface {
 	CheckCSRF(r *http.Request) (bool, error)
 }
 
+// GRPCAuthorizer is implemented by authorizers with native support of gRPC
+// calls. md is incoming metadata, metadata.MD can be passed as is.
+// Authorizers without it serve gRPC through AuthRest, see serviceauth.AuthGRPC.
+type GRPCAuthorizer interface {
+	AuthGRPC(ctx context.Context, md map[string][]string) (Actor, error)
+}
+
+// TelegramAuthorizer is implemented by authorizers of Telegram bot updates
+type TelegramAuthorizer interface {
+	AuthTelegram(ctx context.Context, update TelegramUpdate) (Actor, error)
+}
+
+// TelegramUpdate is the library independent part of a Telegram update used for authentication
+type TelegramUpdate struct {
+	UpdateID int64
+	// UserID is Telegram ID of the sender, 0 for updates without a sender (e.g. channel posts)
+	UserID   int64
+	Username string
+	ChatID   int64
+}
+
 type Namable interface {
 	Name() string
 }
@@ -60,6 +81,14 @@ type AppInfo struct {
 	BuildOS     string `json:"build_os"`
 	BuildCommit string `json:"build_commit"`
 	StartupTime string `json:"startup_time"`
+	GoMaxProcs  int    `json:"gomaxprocs,omitempty"`
+	GoMemLimit  int64  `json:"gomemlimit,omitempty"`
+	VCSModified bool   `json:"vcs_modified"`
+	VCSTime     string `json:"vcs_time,omitempty"`
+	GoVersion   string `json:"go_version,omitempty"`
+	GOOS        string `json:"goos,omitempty"`
+	GOARCH      string `json:"goarch,omitempty"`
+	Hostname    string `json:"hostname,omitempty"`
 }
 
 type AuthorizationData struct {
@@ -79,22 +108,36 @@ func NewAppInfo(name string) *AppInfo {
 	}
 }
 
+// WithVersion sets version. Like other With* setters it ignores empty values,
+// so values detected by AppInfoFromBuildInfo are not wiped out by empty ldflags.
 func (i *AppInfo) WithVersion(version string) *AppInfo {
-	i.Version = version
+	if version != "" {
+		i.Version = version
+	}
+
 	return i
 }
 
 func (i *AppInfo) WithBuildTime(buildTime string) *AppInfo {
-	i.BuildTime = buildTime
+	if buildTime != "" {
+		i.BuildTime = buildTime
+	}
+
 	return i
 }
 
 func (i *AppInfo) WithBuildOS(buildOS string) *AppInfo {
-	i.BuildOS = buildOS
+	if buildOS != "" {
+		i.BuildOS = buildOS
+	}
+
 	return i
 }
 
 func (i *AppInfo) WithBuildCommit(commit string) *AppInfo {
-	i.BuildCommit = commit
+	if commit != "" {
+		i.BuildCommit = commit
+	}
+
 	return i
 }
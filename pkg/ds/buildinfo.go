@@ -0,0 +1,63 @@
+package ds
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+)
+
+const (
+	develVersion = "(devel)"
+
+	settingRevision = "vcs.revision"
+	settingTime     = "vcs.time"
+	settingModified = "vcs.modified"
+)
+
+// readBuildInfo is replaced in tests
+var readBuildInfo = debug.ReadBuildInfo
+
+// AppInfoFromBuildInfo creates AppInfo filled from runtime/debug.ReadBuildInfo:
+// version of the main module, vcs.revision, vcs.time and vcs.modified,
+// Go version, GOOS/GOARCH and hostname.
+// Values set later through With* methods take precedence.
+func AppInfoFromBuildInfo(name string) *AppInfo {
+	info := NewAppInfo(name)
+
+	info.GoVersion = runtime.Version()
+	info.GOOS = runtime.GOOS
+	info.GOARCH = runtime.GOARCH
+	info.BuildOS = runtime.GOOS + "/" + runtime.GOARCH
+
+	if hostname, err := os.Hostname(); err == nil {
+		info.Hostname = hostname
+	}
+
+	bi, ok := readBuildInfo()
+	if !ok {
+		return info
+	}
+
+	if bi.GoVersion != "" {
+		info.GoVersion = bi.GoVersion
+	}
+
+	if bi.Main.Version != develVersion {
+		info.Version = bi.Main.Version
+	}
+
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case settingRevision:
+			info.BuildCommit = s.Value
+		case settingTime:
+			info.VCSTime = s.Value
+			info.BuildTime = s.Value
+		case settingModified:
+			info.VCSModified, _ = strconv.ParseBool(s.Value)
+		}
+	}
+
+	return info
+}
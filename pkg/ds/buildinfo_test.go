@@ -0,0 +1,55 @@
+package ds
+
+import (
+	"runtime"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppInfoFromBuildInfo(t *testing.T) {
+	orig := readBuildInfo
+	t.Cleanup(func() { readBuildInfo = orig })
+
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{
+			GoVersion: "go1.24.4",
+			Main:      debug.Module{Version: "v1.2.3"},
+			Settings: []debug.BuildSetting{
+				{Key: settingRevision, Value: "abc123"},
+				{Key: settingTime, Value: "2025-01-29T10:00:00Z"},
+				{Key: settingModified, Value: "true"},
+			},
+		}, true
+	}
+
+	info := AppInfoFromBuildInfo("app")
+	assert.Equal(t, "app", info.AppName)
+	assert.Equal(t, "v1.2.3", info.Version)
+	assert.Equal(t, "abc123", info.BuildCommit)
+	assert.Equal(t, "2025-01-29T10:00:00Z", info.BuildTime)
+	assert.Equal(t, "2025-01-29T10:00:00Z", info.VCSTime)
+	assert.True(t, info.VCSModified)
+	assert.Equal(t, "go1.24.4", info.GoVersion)
+	assert.Equal(t, runtime.GOOS, info.GOOS)
+	assert.Equal(t, runtime.GOARCH, info.GOARCH)
+
+	// explicit values take precedence, empty ones are ignored
+	info.WithVersion("v2.0.0").WithBuildCommit("")
+	assert.Equal(t, "v2.0.0", info.Version)
+	assert.Equal(t, "abc123", info.BuildCommit)
+}
+
+func TestAppInfoFromBuildInfo_Devel(t *testing.T) {
+	orig := readBuildInfo
+	t.Cleanup(func() { readBuildInfo = orig })
+
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{Main: debug.Module{Version: develVersion}}, true
+	}
+
+	info := AppInfoFromBuildInfo("app")
+	assert.Empty(t, info.Version)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+}
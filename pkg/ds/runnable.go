@@ -0,0 +1,192 @@
+package ds
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+// LoopFunc is a main loop of a component. It must return when ctx is canceled.
+type LoopFunc func(ctx context.Context) error
+
+// BaseRunnable implements OnlyRunnable for a LoopFunc and is meant to be embedded
+// into drivers, transports and workers.
+//
+// The loop context is detached from the context passed to Run, so the loop is
+// stopped only by GracefulStop or Shutdown and the application controls the
+// shutdown order. Errors returned by the loop are propagated to errGr, except
+// context.Canceled after stop.
+type BaseRunnable struct {
+	loop LoopFunc
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+var _ OnlyRunnable = (*BaseRunnable)(nil)
+
+// NewBaseRunnable creates BaseRunnable running loop
+func NewBaseRunnable(loop LoopFunc) *BaseRunnable {
+	return &BaseRunnable{
+		loop: loop,
+		done: make(chan struct{}),
+	}
+}
+
+// Run starts the loop in errGr. Subsequent calls and calls after stop are no-op.
+func (b *BaseRunnable) Run(ctx context.Context, errGr *errgroup.Group) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.started {
+		return
+	}
+
+	b.started = true
+
+	// stopped before start, nothing could cancel the loop
+	if b.stopped {
+		close(b.done)
+		return
+	}
+
+	loopCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	b.cancel = cancel
+
+	errGr.Go(func() error {
+		defer close(b.done)
+		defer cancel()
+
+		err := b.loop(loopCtx)
+		if errors.Is(err, context.Canceled) && loopCtx.Err() != nil {
+			return nil
+		}
+
+		return err
+	})
+}
+
+// stop signals the loop to stop, only the first call has effect
+func (b *BaseRunnable) stop() (started bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.stopped {
+		b.stopped = true
+
+		if b.cancel != nil {
+			b.cancel()
+		}
+	}
+
+	return b.started
+}
+
+// GracefulStop signals the loop to stop and returns a channel closed when the loop returns
+func (b *BaseRunnable) GracefulStop(_ context.Context) (<-chan struct{}, error) {
+	if !b.stop() {
+		stopped := make(chan struct{})
+		close(stopped)
+
+		return stopped, nil
+	}
+
+	return b.done, nil
+}
+
+// Shutdown signals the loop to stop and waits for it until ctx is done
+func (b *BaseRunnable) Shutdown(ctx context.Context) error {
+	if !b.stop() {
+		return nil
+	}
+
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Done returns a channel closed when the loop returns
+func (b *BaseRunnable) Done() <-chan struct{} {
+	return b.done
+}
+
+// LoopRunnable turns a LoopFunc into a Runnable (driver)
+type LoopRunnable struct {
+	*BaseRunnable
+	name string
+
+	// InitFunc is called from Init if set
+	InitFunc func(ctx context.Context, serviceName string, rb ServerBucket, metrics *prometheus.Registry) error
+}
+
+var _ Runnable = (*LoopRunnable)(nil)
+
+// NewLoopRunnable creates a Runnable named name running loop
+func NewLoopRunnable(name string, loop LoopFunc) *LoopRunnable {
+	return &LoopRunnable{
+		BaseRunnable: NewBaseRunnable(loop),
+		name:         name,
+	}
+}
+
+func (r *LoopRunnable) Name() string {
+	return r.name
+}
+
+func (r *LoopRunnable) Init(ctx context.Context, serviceName string, rb ServerBucket, metrics *prometheus.Registry) error {
+	if r.InitFunc == nil {
+		return nil
+	}
+
+	return r.InitFunc(ctx, serviceName, rb, metrics)
+}
+
+// LoopService turns a LoopFunc into a RunnableService (transport or worker)
+type LoopService struct {
+	*BaseRunnable
+	name string
+
+	// InitFunc is called from Init if set
+	InitFunc func(ctx context.Context, serviceName, appName string, metrics *prometheus.Registry, srv IService) error
+	// InitializationFunc is called from Initialization if set
+	InitializationFunc func(ctx context.Context) error
+}
+
+var _ RunnableService = (*LoopService)(nil)
+
+// NewLoopService creates a RunnableService named name running loop
+func NewLoopService(name string, loop LoopFunc) *LoopService {
+	return &LoopService{
+		BaseRunnable: NewBaseRunnable(loop),
+		name:         name,
+	}
+}
+
+func (s *LoopService) Name() string {
+	return s.name
+}
+
+func (s *LoopService) Init(ctx context.Context, serviceName, appName string, metrics *prometheus.Registry, srv IService) error {
+	if s.InitFunc == nil {
+		return nil
+	}
+
+	return s.InitFunc(ctx, serviceName, appName, metrics, srv)
+}
+
+func (s *LoopService) Initialization(ctx context.Context) error {
+	if s.InitializationFunc == nil {
+		return nil
+	}
+
+	return s.InitializationFunc(ctx)
+}
@@ -0,0 +1,101 @@
+package ds
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestBaseRunnable_GracefulStop(t *testing.T) {
+	var errGr errgroup.Group
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := NewBaseRunnable(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	b.Run(ctx, &errGr)
+	b.Run(ctx, &errGr)
+
+	// cancellation of run context does not stop the loop
+	cancel()
+	select {
+	case <-b.Done():
+		t.Fatal("loop stopped by run context")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	stopped, err := b.GracefulStop(context.Background())
+	require.NoError(t, err)
+	<-stopped
+
+	_, err = b.GracefulStop(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, b.Shutdown(context.Background()))
+	require.NoError(t, errGr.Wait())
+}
+
+func TestBaseRunnable_PropagatesError(t *testing.T) {
+	var errGr errgroup.Group
+
+	errLoop := errors.New("loop failed")
+
+	b := NewBaseRunnable(func(context.Context) error { return errLoop })
+	b.Run(context.Background(), &errGr)
+
+	assert.ErrorIs(t, errGr.Wait(), errLoop)
+}
+
+func TestBaseRunnable_StopBeforeRun(t *testing.T) {
+	b := NewBaseRunnable(func(context.Context) error { return nil })
+
+	stopped, err := b.GracefulStop(context.Background())
+	require.NoError(t, err)
+	<-stopped
+
+	require.NoError(t, b.Shutdown(context.Background()))
+
+	// the loop is not started after stop
+	var errGr errgroup.Group
+
+	called := false
+	b.loop = func(context.Context) error {
+		called = true
+		return nil
+	}
+
+	b.Run(context.Background(), &errGr)
+	<-b.Done()
+	require.NoError(t, errGr.Wait())
+	assert.False(t, called)
+
+	stopped, err = b.GracefulStop(context.Background())
+	require.NoError(t, err)
+	<-stopped
+}
+
+func TestBaseRunnable_ShutdownTimeout(t *testing.T) {
+	var errGr errgroup.Group
+
+	release := make(chan struct{})
+	defer close(release)
+
+	b := NewBaseRunnable(func(context.Context) error {
+		<-release
+		return nil
+	})
+	b.Run(context.Background(), &errGr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, b.Shutdown(ctx), context.DeadlineExceeded)
+}
@@ -22,6 +22,17 @@ func CopyLoggerContext(source context.Context, destination context.Context) (con
 	return context.WithValue(destination, loggerContextKey, ILogg), nil
 }
 
+// CopyLogger is a reqctx.ContextCallback copying the logger from source to
+// destination as is, sources without a logger are ignored
+func CopyLogger(source, destination context.Context, _, _ string) context.Context {
+	nCtx, err := CopyLoggerContext(source, destination)
+	if err != nil {
+		return destination
+	}
+
+	return nCtx
+}
+
 func Wrap(ctx context.Context, logger any) context.Context {
 	return context.WithValue(ctx, loggerContextKey, logger)
 }
@@ -31,8 +31,11 @@ func FromContextLoggerZlog(ctx context.Context) AppZlogLogger {
 	panic("AppZlogLogger not found in context")
 }
 
+var _ reqctx.ContextCallback = ReWrapZlog
+
 // ReWrapZlog позволяет переложить логгер из одного контекста в другой
-// а так же создать инстанс zlog-а в контексте получателе
+// а так же создать инстанс zlog-а в контексте получателе.
+// Подходит как reqctx.ContextCallback для reqctx.RegisterContextCallbacks
 func ReWrapZlog(source context.Context, destination context.Context, ocPrefix, ocPath string) context.Context {
 	nCtx, err := CopyLoggerContext(source, destination)
 	if err != nil {
@@ -5,11 +5,55 @@
 package actor
 
 import (
+	"time"
+
+	"github.com/google/uuid"
+
 	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
 )
 
+// Kind of the actor
+type Kind string
+
+const (
+	// KindUser is a human user, the default
+	KindUser Kind = "user"
+	// KindService is another service or a service account
+	KindService Kind = "service"
+	// KindAnonymous is an unauthenticated caller
+	KindAnonymous Kind = "anonymous"
+	// KindSystem is the application itself: cron jobs, workers, migrations
+	KindSystem Kind = "system"
+)
+
+// KindProvider is implemented by actors of explicit kind
+type KindProvider interface {
+	GetKind() Kind
+}
+
+// StringIDProvider is implemented by actors with string (e.g. UUID) identifiers
+type StringIDProvider interface {
+	GetStringID() string
+}
+
 type Actor struct {
 	ID int64
+	// Kind is KindUser when empty
+	Kind Kind
+	// StringID is a non numeric identifier, e.g. UUID of an external account
+	StringID string
+	// Name is a display name, it is not added to logs
+	Name string
+	// AuthMethod is the name of the authorizer the actor was authenticated by
+	AuthMethod string
+	// ExpiresAt is the expiry of credentials the actor was authenticated by, zero if unknown
+	ExpiresAt time.Time
+	// Roles are checked against the authz policy
+	Roles []string
+	// Scopes restrict permissions of delegated credentials, e.g. OAuth tokens
+	Scopes []string
+	// Attributes are used in conditions of the authz policy
+	Attributes map[string]string
 }
 
 func New(aData ds.AuthorizationData) *Actor {
@@ -18,6 +62,131 @@ func New(aData ds.AuthorizationData) *Actor {
 	}
 }
 
+// NewAnonymous returns an actor of an unauthenticated caller
+func NewAnonymous() *Actor {
+	return &Actor{Kind: KindAnonymous}
+}
+
+// NewSystem returns an actor of the application itself, name is e.g. the cron job
+func NewSystem(name string) *Actor {
+	return &Actor{Kind: KindSystem, Name: name}
+}
+
 func (a *Actor) GetID() int64 {
 	return a.ID
 }
+
+// GetKind returns the kind of the actor
+func (a *Actor) GetKind() Kind {
+	if a.Kind == "" {
+		return KindUser
+	}
+
+	return a.Kind
+}
+
+// GetStringID returns StringID
+func (a *Actor) GetStringID() string {
+	return a.StringID
+}
+
+// GetUUID parses StringID as UUID
+func (a *Actor) GetUUID() (uuid.UUID, bool) {
+	id, err := uuid.Parse(a.StringID)
+
+	return id, err == nil
+}
+
+// GetName returns the display name
+func (a *Actor) GetName() string {
+	return a.Name
+}
+
+// IsAuthenticated reports whether the actor is not anonymous
+func (a *Actor) IsAuthenticated() bool {
+	return a.GetKind() != KindAnonymous
+}
+
+// GetAuthMethod returns the name of the authorizer the actor was authenticated by
+func (a *Actor) GetAuthMethod() string {
+	return a.AuthMethod
+}
+
+// GetExpiresAt returns the expiry of credentials the actor was authenticated by
+func (a *Actor) GetExpiresAt() time.Time {
+	return a.ExpiresAt
+}
+
+// GetRoles returns roles of the actor
+func (a *Actor) GetRoles() []string {
+	return a.Roles
+}
+
+// GetScopes returns scopes of the actor
+func (a *Actor) GetScopes() []string {
+	return a.Scopes
+}
+
+// GetAttribute returns an attribute of the actor
+func (a *Actor) GetAttribute(key string) (string, bool) {
+	v, ok := a.Attributes[key]
+
+	return v, ok
+}
+
+// SetAuthMethod sets the authorizer of an actor owned by the caller. Actors
+// returned by other authorizers may be shared, use WithAuthMethod for them.
+func (a *Actor) SetAuthMethod(method string) {
+	a.AuthMethod = method
+}
+
+// WithAuthMethod returns a copy of the actor authenticated by method, it is
+// used by composite authorizers
+func (a *Actor) WithAuthMethod(method string) ds.Actor {
+	c := *a
+	c.AuthMethod = method
+
+	return &c
+}
+
+// ServiceActor is an actor of another service authenticated by its identity,
+// e.g. by mTLS client certificate
+type ServiceActor struct {
+	Actor
+	// Service name from the identity table
+	Service string
+	// Identity is SPIFFE ID or certificate CN the actor was matched by
+	Identity string
+}
+
+// GetKind returns KindService
+func (a *ServiceActor) GetKind() Kind {
+	return KindService
+}
+
+// WithAuthMethod returns a copy of the actor authenticated by method
+func (a *ServiceActor) WithAuthMethod(method string) ds.Actor {
+	c := *a
+	c.AuthMethod = method
+
+	return &c
+}
+
+// GetService returns the name of the calling service
+func (a *ServiceActor) GetService() string {
+	return a.Service
+}
+
+// KindOf returns the kind of any actor: actors without KindProvider are
+// users, nil is anonymous
+func KindOf(act ds.Actor) Kind {
+	if act == nil {
+		return KindAnonymous
+	}
+
+	if p, ok := act.(KindProvider); ok {
+		return p.GetKind()
+	}
+
+	return KindUser
+}
@@ -0,0 +1,48 @@
+package actor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+)
+
+type plainActor struct{}
+
+func (plainActor) GetID() int64 { return 1 }
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		name string
+		act  ds.Actor
+		want Kind
+	}{
+		{"nil", nil, KindAnonymous},
+		{"without provider", plainActor{}, KindUser},
+		{"default", &Actor{ID: 1}, KindUser},
+		{"anonymous", NewAnonymous(), KindAnonymous},
+		{"system", NewSystem("cleanup"), KindSystem},
+		{"service", &ServiceActor{Actor: Actor{ID: 2}}, KindService},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, KindOf(tt.act))
+		})
+	}
+
+	assert.False(t, NewAnonymous().IsAuthenticated())
+	assert.True(t, NewSystem("cleanup").IsAuthenticated())
+}
+
+func TestGetUUID(t *testing.T) {
+	act := &Actor{StringID: "0190a4c5-5a3c-7f1e-9b7d-2f4c9a1e8b10"}
+
+	id, ok := act.GetUUID()
+	assert.True(t, ok)
+	assert.Equal(t, act.StringID, id.String())
+
+	_, ok = (&Actor{StringID: "not-a-uuid"}).GetUUID()
+	assert.False(t, ok)
+}
@@ -0,0 +1,237 @@
+package reqctx
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HeaderBudget carries the remaining time budget of the caller in milliseconds
+const HeaderBudget = "X-Request-Budget"
+
+// DefaultBudgetMargin is subtracted from the remaining budget of outgoing calls
+// to leave the caller time to handle the response
+const DefaultBudgetMargin = 50 * time.Millisecond
+
+// Stages of budget exceeded outcomes
+const (
+	// BudgetStageInbound is a request received with an exhausted budget
+	BudgetStageInbound = "inbound"
+	// BudgetStageOutbound is an outgoing call not sent as the budget is exhausted
+	BudgetStageOutbound = "outbound"
+	// BudgetStageDeadline is a request which ran out of the propagated budget
+	BudgetStageDeadline = "deadline"
+)
+
+// ErrBudgetExhausted is returned when the remaining budget of a request is exhausted
+var ErrBudgetExhausted = fmt.Errorf("request budget exhausted")
+
+var budgetMargin = DefaultBudgetMargin
+
+// SetBudgetMargin overrides DefaultBudgetMargin.
+// This should be called once during application initialization.
+func SetBudgetMargin(margin time.Duration) {
+	budgetMargin = margin
+}
+
+var budgetExceeded = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Subsystem: "request",
+		Name:      "budget_exceeded_total",
+		Help:      "Total number of requests and outgoing calls which exceeded the propagated time budget by stage",
+	},
+	[]string{"stage"},
+)
+
+// BudgetCollectors returns metrics of budget propagation, they are registered by App.InitMetrics
+func BudgetCollectors() []prometheus.Collector {
+	return []prometheus.Collector{budgetExceeded}
+}
+
+// maxBudgetMillis is the largest budget in milliseconds a time.Duration holds
+const maxBudgetMillis = math.MaxInt64 / int64(time.Millisecond)
+
+// ParseBudget parses the value of HeaderBudget, ok is false for absent or
+// invalid values, including budgets which overflow time.Duration. Budgets
+// which are not positive are valid and exhausted.
+func ParseBudget(value string) (time.Duration, bool) {
+	ms, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || ms > maxBudgetMillis || ms < -maxBudgetMillis {
+		return 0, false
+	}
+
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// WithBudget stores the deadline of the incoming budget in ctx. CreateContext
+// limits the configured timeout by it. ErrBudgetExhausted is returned if
+// budget is not positive.
+func WithBudget(ctx context.Context, budget time.Duration) (context.Context, error) {
+	if budget <= 0 {
+		budgetExceeded.WithLabelValues(BudgetStageInbound).Inc()
+		return ctx, ErrBudgetExhausted
+	}
+
+	return context.WithValue(ctx, budgetDeadlineField, time.Now().Add(budget)), nil
+}
+
+// GetBudgetDeadline returns the deadline of the incoming budget set by WithBudget
+func GetBudgetDeadline(ctx context.Context) (time.Time, bool) {
+	deadline, ok := ctx.Value(budgetDeadlineField).(time.Time)
+	return deadline, ok
+}
+
+// limitByBudget returns the smaller of timeout (zero means none) and the
+// remaining incoming budget of ctx
+func limitByBudget(ctx context.Context, timeout time.Duration) (time.Duration, error) {
+	deadline, ok := GetBudgetDeadline(ctx)
+	if !ok {
+		return timeout, nil
+	}
+
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		budgetExceeded.WithLabelValues(BudgetStageInbound).Inc()
+		return 0, ErrBudgetExhausted
+	}
+
+	if timeout == 0 || remaining < timeout {
+		return remaining, nil
+	}
+
+	return timeout, nil
+}
+
+// OutgoingBudget returns the budget of outgoing calls: the time left until
+// the deadline of ctx minus the margin. ok is false if ctx has no deadline.
+func OutgoingBudget(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+
+	return time.Until(deadline) - budgetMargin, true
+}
+
+// outgoingBudget formats the budget of ctx for HeaderBudget, "" if ctx has no deadline
+func outgoingBudget(ctx context.Context) (string, error) {
+	budget, ok := OutgoingBudget(ctx)
+	if !ok {
+		return "", nil
+	}
+
+	if budget < time.Millisecond {
+		budgetExceeded.WithLabelValues(BudgetStageOutbound).Inc()
+		return "", ErrBudgetExhausted
+	}
+
+	return strconv.FormatInt(budget.Milliseconds(), 10), nil
+}
+
+// InjectBudget sets HeaderBudget of an outgoing request. ErrBudgetExhausted is
+// returned, and the call shouldn't be made, if nothing is left after the margin.
+func InjectBudget(ctx context.Context, h http.Header) error {
+	value, err := outgoingBudget(ctx)
+	if err != nil || value == "" {
+		return err
+	}
+
+	h.Set(HeaderBudget, value)
+
+	return nil
+}
+
+// InjectBudgetGRPC is InjectBudget for outgoing gRPC metadata, metadata.MD can be passed as is
+func InjectBudgetGRPC(ctx context.Context, md map[string][]string) error {
+	value, err := outgoingBudget(ctx)
+	if err != nil || value == "" {
+		return err
+	}
+
+	md[strings.ToLower(HeaderBudget)] = []string{value}
+
+	return nil
+}
+
+// BudgetTransport injects the budget of the request context into outgoing
+// requests, calls with an exhausted budget fail without being sent
+func BudgetTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return budgetRoundTripper{base: base}
+}
+
+type budgetRoundTripper struct {
+	base http.RoundTripper
+}
+
+func (t budgetRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	value, err := outgoingBudget(r.Context())
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s %s", r.Method, r.URL.Redacted())
+	}
+
+	if value != "" {
+		r = r.Clone(r.Context())
+		r.Header.Set(HeaderBudget, value)
+	}
+
+	return t.base.RoundTrip(r)
+}
+
+// BudgetMiddleware accepts HeaderBudget of incoming requests, requests with an
+// exhausted budget are rejected with 504 Gateway Timeout
+func BudgetMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		budget, ok := ParseBudget(r.Header.Get(HeaderBudget))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, err := WithBudget(r.Context(), budget)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusGatewayTimeout)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// BudgetFromGRPC is WithBudget for the budget of incoming gRPC metadata,
+// metadata.MD can be passed as is. ctx is returned as is if there is no budget.
+func BudgetFromGRPC(ctx context.Context, md map[string][]string) (context.Context, error) {
+	values := md[strings.ToLower(HeaderBudget)]
+	if len(values) == 0 {
+		return ctx, nil
+	}
+
+	budget, ok := ParseBudget(values[0])
+	if !ok {
+		return ctx, nil
+	}
+
+	return WithBudget(ctx, budget)
+}
+
+// ObserveBudget counts err of a request handled in ctx if it ran out of the
+// incoming budget. It returns true if err was counted.
+func ObserveBudget(ctx context.Context, err error) bool {
+	if _, ok := GetBudgetDeadline(ctx); !ok || !errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	budgetExceeded.WithLabelValues(BudgetStageDeadline).Inc()
+
+	return true
+}
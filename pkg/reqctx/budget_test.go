@@ -0,0 +1,153 @@
+package reqctx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateContext_Budget(t *testing.T) {
+	SetDefaultContextOptions(WithStaticConfig(StaticTimeouts{Default: time.Minute}))
+	t.Cleanup(func() { SetDefaultContextOptions() })
+
+	source, err := WithBudget(context.Background(), 2*time.Second)
+	require.NoError(t, err)
+
+	ctx, cancel, err := CreateContext(context.Background(), source, "/app", "orders")
+	require.NoError(t, err)
+
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.LessOrEqual(t, time.Until(deadline), 2*time.Second, "budget is lower than the timeout")
+
+	// the configured timeout is lower than the budget
+	source, err = WithBudget(context.Background(), time.Hour)
+	require.NoError(t, err)
+
+	ctx, cancel, err = CreateContext(context.Background(), source, "/app", "orders")
+	require.NoError(t, err)
+
+	defer cancel()
+
+	deadline, _ = ctx.Deadline()
+	assert.Greater(t, time.Until(deadline), 50*time.Second)
+
+	// the budget is spent while waiting
+	source = context.WithValue(context.Background(), budgetDeadlineField, time.Now().Add(-time.Millisecond))
+
+	before := testutil.ToFloat64(budgetExceeded.WithLabelValues(BudgetStageInbound))
+
+	_, _, err = CreateContext(context.Background(), source, "/app", "orders")
+	require.ErrorIs(t, err, ErrBudgetExhausted)
+	assert.Equal(t, before+1, testutil.ToFloat64(budgetExceeded.WithLabelValues(BudgetStageInbound)))
+}
+
+func TestBudget_HTTP(t *testing.T) {
+	SetBudgetMargin(100 * time.Millisecond)
+	t.Cleanup(func() { SetBudgetMargin(DefaultBudgetMargin) })
+
+	var received string
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(HeaderBudget)
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: BudgetTransport(nil)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	ms, err := strconv.Atoi(received)
+	require.NoError(t, err)
+	assert.Greater(t, ms, 800)
+	assert.LessOrEqual(t, ms, 900)
+
+	// the margin is larger than the time left
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+	require.NoError(t, err)
+
+	_, err = client.Do(req)
+	require.ErrorIs(t, err, ErrBudgetExhausted)
+
+	// inbound
+	var deadline time.Time
+
+	handler := BudgetMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, _ = GetBudgetDeadline(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HeaderBudget, "500")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.WithinDuration(t, time.Now().Add(500*time.Millisecond), deadline, 100*time.Millisecond)
+
+	r.Header.Set(HeaderBudget, "0")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+}
+
+func TestParseBudget(t *testing.T) {
+	budget, ok := ParseBudget(" 250 ")
+	assert.True(t, ok)
+	assert.Equal(t, 250*time.Millisecond, budget)
+
+	budget, ok = ParseBudget("0")
+	assert.True(t, ok, "exhausted budget is valid")
+	assert.Zero(t, budget)
+
+	for _, value := range []string{"", "abc", "9223372036854775807", "-9223372036854775807", "9223372036855"} {
+		_, ok = ParseBudget(value)
+		assert.False(t, ok, value)
+	}
+
+	budget, ok = ParseBudget("9223372036854")
+	assert.True(t, ok)
+	assert.Positive(t, budget)
+}
+
+func TestBudget_GRPC(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	md := map[string][]string{}
+	require.NoError(t, InjectBudgetGRPC(ctx, md))
+	require.Len(t, md["x-request-budget"], 1)
+
+	incoming, err := BudgetFromGRPC(context.Background(), md)
+	require.NoError(t, err)
+
+	_, ok := GetBudgetDeadline(incoming)
+	assert.True(t, ok)
+
+	_, err = BudgetFromGRPC(context.Background(), map[string][]string{"x-request-budget": {"-5"}})
+	require.ErrorIs(t, err, ErrBudgetExhausted)
+
+	assert.True(t, ObserveBudget(incoming, errors.Join(errors.New("query"), context.DeadlineExceeded)))
+	assert.False(t, ObserveBudget(context.Background(), context.DeadlineExceeded))
+}
@@ -0,0 +1,84 @@
+package reqctx
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ContextCallback transforms the context created by CreateContext. source is
+// configCtx (the incoming request context), dest is the new context derived
+// from mainCtx. Callbacks are called in order, each receiving the result of
+// the previous one.
+type ContextCallback func(source, dest context.Context, configPathPrefix, configPath string) context.Context
+
+// copiedFields are copied by CopyRequestValues
+var copiedFields = []ctxKey{
+	actorField,
+	realActorField,
+	authMethodField,
+	requestIDField,
+	requestStartTimeField,
+	processInfoField,
+	budgetDeadlineField,
+}
+
+// CopyRequestValues copies the actor (with the real actor of impersonation and
+// the auth method), request ID, request start time, process info and the
+// deadline of the incoming budget. Logger fields are not touched, they are
+// copied with the logger, e.g. by logger.ReWrapZlog.
+func CopyRequestValues(source, dest context.Context, _, _ string) context.Context {
+	for _, field := range copiedFields {
+		if v := source.Value(field); v != nil {
+			dest = context.WithValue(dest, field, v)
+		}
+	}
+
+	return dest
+}
+
+// PropagateSpan makes the span and baggage of source current in dest
+func PropagateSpan(source, dest context.Context, _, _ string) context.Context {
+	if span := trace.SpanFromContext(source); span.SpanContext().IsValid() {
+		dest = trace.ContextWithSpan(dest, span)
+	}
+
+	if b := baggage.FromContext(source); b.Len() > 0 {
+		dest = baggage.ContextWithBaggage(dest, b)
+	}
+
+	return dest
+}
+
+// WithCallbacks adds callbacks called after the callbacks registered by RegisterContextCallbacks
+func WithCallbacks(callbacks ...ContextCallback) ContextOption {
+	return func(o *contextOptions) {
+		o.callbacks = append(o.callbacks, callbacks...)
+	}
+}
+
+var defaultCallbacks []ContextCallback
+
+// RegisterContextCallbacks adds callbacks called by every CreateContext and
+// CreateContextWith, e.g.:
+//
+//	reqctx.RegisterContextCallbacks(logger.ReWrapZlog, reqctx.CopyRequestValues, reqctx.PropagateSpan)
+//
+// This should be called once during application initialization.
+func RegisterContextCallbacks(callbacks ...ContextCallback) {
+	defaultCallbacks = append(defaultCallbacks, callbacks...)
+}
+
+// ResetContextCallbacks removes callbacks registered by RegisterContextCallbacks
+func ResetContextCallbacks() {
+	defaultCallbacks = nil
+}
+
+func runCallbacks(source, dest context.Context, configPathPrefix, configPath string, callbacks []ContextCallback) context.Context {
+	for _, cb := range callbacks {
+		dest = cb(source, dest, configPathPrefix, configPath)
+	}
+
+	return dest
+}
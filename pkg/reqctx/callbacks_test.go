@@ -0,0 +1,64 @@
+package reqctx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+)
+
+func TestCreateContext_Callbacks(t *testing.T) {
+	SetDefaultContextOptions(WithStaticConfig(StaticTimeouts{}))
+	RegisterContextCallbacks(CopyRequestValues, PropagateSpan)
+
+	t.Cleanup(func() {
+		SetDefaultContextOptions()
+		ResetContextCallbacks()
+	})
+
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})
+
+	source := trace.ContextWithSpanContext(context.Background(), spanCtx)
+	source = SetRequestStartTime(source, time.Unix(1_700_000_000, 0))
+
+	source, err := SetRequestID(source, "rid-1")
+	require.NoError(t, err)
+
+	source, err = SetActor(source, &actor.Actor{ID: 5})
+	require.NoError(t, err)
+
+	var order []string
+
+	ctx, cancel, err := CreateContext(context.Background(), source, "/app", "orders",
+		func(_, dest context.Context, _, path string) context.Context {
+			order = append(order, path)
+			return dest
+		})
+	require.NoError(t, err)
+
+	defer cancel()
+
+	assert.Equal(t, []string{"orders"}, order)
+
+	act, err := GetActor(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), act.GetID())
+
+	rid, err := GetRequestID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "rid-1", rid)
+
+	start, err := GetRequestStartTime(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1_700_000_000), start.Unix())
+
+	assert.Equal(t, spanCtx, trace.SpanContextFromContext(ctx))
+}
@@ -0,0 +1,145 @@
+package reqctx
+
+import (
+	"context"
+	"time"
+
+	"github.com/Educentr/go-onlineconf/pkg/onlineconf"
+	"github.com/pkg/errors"
+)
+
+// TimeoutSource returns the timeout of requests to configPath, zero means no timeout
+type TimeoutSource interface {
+	Timeout(configCtx context.Context, configPathPrefix, configPath string) (time.Duration, error)
+}
+
+// ConfigSnapshotter binds a consistent snapshot of the configuration from
+// configCtx to the request context for the lifetime of the request
+type ConfigSnapshotter interface {
+	// Snapshot returns mainCtx with the snapshot and a function releasing it
+	Snapshot(configCtx, mainCtx context.Context) (context.Context, func(), error)
+}
+
+// OnlineconfTimeouts reads <prefix>/<path>/timeout, falling back to <prefix>/default/timeout
+type OnlineconfTimeouts struct{}
+
+var _ TimeoutSource = OnlineconfTimeouts{}
+
+func (OnlineconfTimeouts) Timeout(configCtx context.Context, configPathPrefix, configPath string) (time.Duration, error) {
+	ocDefaultPath := onlineconf.MakePath(configPathPrefix, "default/timeout")
+	ocPath := onlineconf.MakePath(configPathPrefix, configPath, "timeout")
+
+	timeoutDef, err := onlineconf.GetDuration(configCtx, ocDefaultPath, 0)
+	if err != nil {
+		return 0, errors.Wrapf(err, "get default timeout from %s", ocDefaultPath)
+	}
+
+	timeout, err := onlineconf.GetDuration(configCtx, ocPath, timeoutDef)
+	if err != nil {
+		return 0, errors.Wrapf(err, "get timeout from %s", ocPath)
+	}
+
+	return timeout, nil
+}
+
+// OnlineconfSnapshotter clones onlineconf config of configCtx into the request context
+type OnlineconfSnapshotter struct{}
+
+var _ ConfigSnapshotter = OnlineconfSnapshotter{}
+
+func (OnlineconfSnapshotter) Snapshot(configCtx, mainCtx context.Context) (context.Context, func(), error) {
+	clonedCtx, err := onlineconf.Clone(configCtx, mainCtx)
+	if err != nil {
+		return mainCtx, nil, err
+	}
+
+	return clonedCtx, func() {
+		_ = onlineconf.Release(configCtx, clonedCtx)
+	}, nil
+}
+
+// StaticTimeouts returns timeouts from memory, e.g. in tests
+type StaticTimeouts struct {
+	// Default is used for paths missing in Paths
+	Default time.Duration
+	// Paths are timeouts by config path, the prefix is ignored
+	Paths map[string]time.Duration
+}
+
+var _ TimeoutSource = StaticTimeouts{}
+
+func (s StaticTimeouts) Timeout(_ context.Context, _, configPath string) (time.Duration, error) {
+	if timeout, ok := s.Paths[configPath]; ok {
+		return timeout, nil
+	}
+
+	return s.Default, nil
+}
+
+// NoopSnapshotter leaves the request context as is, for code without onlineconf
+type NoopSnapshotter struct{}
+
+var _ ConfigSnapshotter = NoopSnapshotter{}
+
+func (NoopSnapshotter) Snapshot(_, mainCtx context.Context) (context.Context, func(), error) {
+	return mainCtx, func() {}, nil
+}
+
+type contextOptions struct {
+	timeouts  TimeoutSource
+	snapshots ConfigSnapshotter
+	callbacks []ContextCallback
+}
+
+// ContextOption configures CreateContextWith
+type ContextOption func(*contextOptions)
+
+// WithTimeoutSource sets the source of request timeouts, OnlineconfTimeouts by default
+func WithTimeoutSource(s TimeoutSource) ContextOption {
+	return func(o *contextOptions) {
+		o.timeouts = s
+	}
+}
+
+// WithConfigSnapshotter sets the config snapshotter, OnlineconfSnapshotter by default
+func WithConfigSnapshotter(s ConfigSnapshotter) ContextOption {
+	return func(o *contextOptions) {
+		o.snapshots = s
+	}
+}
+
+// WithStaticConfig uses static timeouts and no config snapshot, so handlers
+// run without onlineconf
+func WithStaticConfig(timeouts StaticTimeouts) ContextOption {
+	return func(o *contextOptions) {
+		o.timeouts = timeouts
+		o.snapshots = NoopSnapshotter{}
+	}
+}
+
+var defaultContextOptions []ContextOption
+
+// SetDefaultContextOptions sets options applied by CreateContext and before
+// options of every CreateContextWith call, e.g. WithStaticConfig in tests of
+// REST handlers. This should be called once during application initialization.
+func SetDefaultContextOptions(opts ...ContextOption) {
+	defaultContextOptions = opts
+}
+
+func newContextOptions(opts []ContextOption) contextOptions {
+	o := contextOptions{
+		timeouts:  OnlineconfTimeouts{},
+		snapshots: OnlineconfSnapshotter{},
+		callbacks: append([]ContextCallback(nil), defaultCallbacks...),
+	}
+
+	for _, opt := range defaultContextOptions {
+		opt(&o)
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
@@ -0,0 +1,77 @@
+package reqctx
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Educentr/go-onlineconf/pkg/onlineconf"
+	"github.com/colinmarc/cdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateContextWith_Static(t *testing.T) {
+	ctx, cancel, err := CreateContextWith(context.Background(), context.Background(), "/app", "orders",
+		WithStaticConfig(StaticTimeouts{Default: time.Minute, Paths: map[string]time.Duration{"health": 0}}))
+	require.NoError(t, err)
+
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	cancel()
+	require.ErrorIs(t, ctx.Err(), context.Canceled)
+
+	ctx, cancel, err = CreateContextWith(context.Background(), context.Background(), "/app", "health",
+		WithStaticConfig(StaticTimeouts{Default: time.Minute, Paths: map[string]time.Duration{"health": 0}}))
+	require.NoError(t, err)
+
+	defer cancel()
+
+	_, ok = ctx.Deadline()
+	assert.False(t, ok)
+}
+
+func TestCreateContext_DefaultOptions(t *testing.T) {
+	SetDefaultContextOptions(WithStaticConfig(StaticTimeouts{Default: time.Second}))
+	t.Cleanup(func() { SetDefaultContextOptions() })
+
+	ctx, cancel, err := CreateContext(context.Background(), context.Background(), "/app", "orders")
+	require.NoError(t, err)
+
+	defer cancel()
+
+	_, ok := ctx.Deadline()
+	assert.True(t, ok)
+}
+
+func TestOnlineconfTimeouts(t *testing.T) {
+	dir := t.TempDir()
+
+	writer, err := cdb.Create(filepath.Join(dir, onlineconf.DefaultModule+".cdb"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Put([]byte("/app/default/timeout"), []byte("s5s")))
+	require.NoError(t, writer.Put([]byte("/app/orders/timeout"), []byte("s2s")))
+	require.NoError(t, writer.Close())
+
+	configCtx, err := onlineconf.Initialize(context.Background(), onlineconf.WithConfigDir(dir))
+	require.NoError(t, err)
+
+	timeout, err := OnlineconfTimeouts{}.Timeout(configCtx, "/app", "orders")
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, timeout)
+
+	timeout, err = OnlineconfTimeouts{}.Timeout(configCtx, "/app", "users")
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, timeout)
+
+	ctx, cancel, err := CreateContext(context.Background(), configCtx, "/app", "orders")
+	require.NoError(t, err)
+
+	defer cancel()
+
+	_, ok := ctx.Deadline()
+	assert.True(t, ok)
+}
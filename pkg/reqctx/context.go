@@ -5,11 +5,11 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/Educentr/go-onlineconf/pkg/onlineconf"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
 )
 
 type ctxKey int
@@ -21,6 +21,9 @@ const (
 	metricHist
 	metricCount
 	processInfoField
+	authMethodField
+	realActorField
+	budgetDeadlineField
 )
 
 var (
@@ -33,57 +36,57 @@ var (
 )
 
 // CreateContext creates a new context with cloned onlineconf config and timeout.
-// Note: This function does NOT wrap the logger - that's the responsibility of the calling code.
-// The caller should wrap the logger after calling this function if needed.
+// It is CreateContextWith without options, see SetDefaultContextOptions to
+// replace onlineconf e.g. in tests.
+// Note: This function wraps the logger only by callbacks registered with
+// RegisterContextCallbacks (e.g. logger.ReWrapZlog), otherwise that's the
+// responsibility of the calling code.
 //
 // Returns error if onlineconf config cloning fails. Callers should handle this error
 // appropriately (e.g., return 500 Internal Server Error in REST handlers).
-//
-// TODO: Refactor to remove onlineconf dependency:
-// 1. onlineconf.Clone should return a cleanup callback that does Release
-// 2. CreateContext should accept timeout settings as input parameter (not fetch from onlineconf)
-// 3. This will allow runtime to be independent of configuration system
-//
-// TODO: Add callback support for context creation hooks:
-// 1. Accept variadic callback functions that will be called after context creation
-// 2. Each callback receives (sourceCtx, newCtx, configPathPrefix, configPath) and returns modified context
-// 3. This allows automatic logger rewrapping and other context transformations
-// 4. Example signature: CreateContext(..., callbacks ...ContextCallback) where
-//    type ContextCallback func(source, dest context.Context, prefix, path string) context.Context
-// 5. Callbacks are called in order, each receiving the result of the previous one
-func CreateContext(mainCtx, configCtx context.Context, configPathPrefix, configPath string) (context.Context, context.CancelFunc, error) {
-	// Get timeout from onlineconf before cloning
-	ocDefaultPath := onlineconf.MakePath(configPathPrefix, "default/timeout")
-	ocPath := onlineconf.MakePath(configPathPrefix, configPath, "timeout")
-
-	timeoutDef, err := onlineconf.GetDuration(configCtx, ocDefaultPath, 0)
+func CreateContext(mainCtx, configCtx context.Context, configPathPrefix, configPath string, callbacks ...ContextCallback) (context.Context, context.CancelFunc, error) {
+	return CreateContextWith(mainCtx, configCtx, configPathPrefix, configPath, WithCallbacks(callbacks...))
+}
+
+// CreateContextWith creates a new context with the config snapshot and the
+// timeout of configPath. By default both come from onlineconf, options
+// replace them with other TimeoutSource and ConfigSnapshotter. The timeout is
+// limited by the budget of the caller (see WithBudget), ErrBudgetExhausted is
+// returned if nothing is left. Registered and passed callbacks are then
+// called with configCtx as the source.
+func CreateContextWith(mainCtx, configCtx context.Context, configPathPrefix, configPath string, opts ...ContextOption) (context.Context, context.CancelFunc, error) {
+	o := newContextOptions(opts)
+
+	// Get timeout before taking the snapshot
+	timeout, err := o.timeouts.Timeout(configCtx, configPathPrefix, configPath)
 	if err != nil {
-		return mainCtx, func() {}, errors.Wrapf(ErrCreateContext, "get default timeout from %s: %v", ocDefaultPath, err)
+		return mainCtx, func() {}, errors.Wrap(ErrCreateContext, err.Error())
 	}
 
-	timeout, err := onlineconf.GetDuration(configCtx, ocPath, timeoutDef)
-	if err != nil {
-		return mainCtx, func() {}, errors.Wrapf(ErrCreateContext, "get timeout from %s: %v", ocPath, err)
+	// the caller won't wait longer than its propagated budget
+	if timeout, err = limitByBudget(configCtx, timeout); err != nil {
+		return mainCtx, func() {}, errors.WithMessage(err, ErrCreateContext.Error())
 	}
 
-	// Clone onlineconf config from main context
-	clonedCtx, err := onlineconf.Clone(configCtx, mainCtx)
+	snapshotCtx, release, err := o.snapshots.Snapshot(configCtx, mainCtx)
 	if err != nil {
 		return mainCtx, func() {}, errors.Wrap(ErrCreateContext, err.Error())
 	}
 
-	// resultCtx will be wrapped with timeout if needed, but we keep clonedCtx
-	// separately for onlineconf.Release which requires the original cloned context
-	resultCtx := clonedCtx
+	// resultCtx will be wrapped with timeout if needed, but the snapshot is
+	// released separately as onlineconf.Release requires the original cloned context
+	resultCtx := snapshotCtx
 	var cancel context.CancelFunc = func() {}
 
 	if timeout != 0 {
-		resultCtx, cancel = context.WithTimeout(clonedCtx, timeout)
+		resultCtx, cancel = context.WithTimeout(snapshotCtx, timeout)
 	}
 
+	resultCtx = runCallbacks(configCtx, resultCtx, configPathPrefix, configPath, o.callbacks)
+
 	return resultCtx, func() {
 		cancel()
-		_ = onlineconf.Release(configCtx, clonedCtx)
+		release()
 	}, nil
 }
 
@@ -101,21 +104,95 @@ func GetActor(ctx context.Context) (ds.Actor, error) {
 	return curActor, nil
 }
 
+// SetActor stores the actor and adds ActorKind, ActorUID (numeric ID) and
+// ActorSID (string ID) to the logger context. Actors need an ID unless they
+// are anonymous or system ones.
 func SetActor(ctx context.Context, act ds.Actor) (context.Context, error) {
-	if act.GetID() == 0 {
-		return nil, fmt.Errorf("invalid actor: %v", act)
+	if err := validateActor(act); err != nil {
+		return nil, err
 	}
 
 	// Update logger context if updater is set
 	if globalLoggerUpdater != nil {
 		ctx = globalLoggerUpdater.UpdateContext(ctx, func(c LoggerContext) LoggerContext {
-			return c.Int64("ActorUID", act.GetID())
+			return actorFields(c, "Actor", act)
 		})
 	}
 
+	if p, ok := act.(AuthMethodProvider); ok && p.GetAuthMethod() != "" {
+		ctx = SetAuthMethod(ctx, p.GetAuthMethod())
+	}
+
+	// the actor replaces an impersonated one
+	if IsImpersonated(ctx) {
+		ctx = context.WithValue(ctx, realActorField, nil)
+	}
+
 	return context.WithValue(ctx, actorField, act), nil
 }
 
+func validateActor(act ds.Actor) error {
+	if act == nil {
+		return ErrUndefinedActor
+	}
+
+	kind := actor.KindOf(act)
+
+	if act.GetID() == 0 && stringID(act) == "" && kind != actor.KindAnonymous && kind != actor.KindSystem {
+		return fmt.Errorf("invalid actor: %v", act)
+	}
+
+	return nil
+}
+
+func stringID(act ds.Actor) string {
+	if p, ok := act.(actor.StringIDProvider); ok {
+		return p.GetStringID()
+	}
+
+	return ""
+}
+
+// actorFields adds <prefix>Kind, <prefix>UID and <prefix>SID fields
+func actorFields(c LoggerContext, prefix string, act ds.Actor) LoggerContext {
+	c = c.Str(prefix+"Kind", string(actor.KindOf(act)))
+
+	if act.GetID() != 0 {
+		c = c.Int64(prefix+"UID", act.GetID())
+	}
+
+	if sid := stringID(act); sid != "" {
+		c = c.Str(prefix+"SID", sid)
+	}
+
+	return c
+}
+
+// AuthMethodProvider is implemented by actors which know the authorizer they
+// were authenticated by. SetActor stores the method in the context.
+type AuthMethodProvider interface {
+	GetAuthMethod() string
+}
+
+// GetAuthMethod returns the name of the authorizer the request was authenticated by, "" if unknown
+func GetAuthMethod(ctx context.Context) string {
+	method, _ := ctx.Value(authMethodField).(string)
+
+	return method
+}
+
+// SetAuthMethod stores the name of the authorizer the request was authenticated by
+func SetAuthMethod(ctx context.Context, method string) context.Context {
+	// Update logger context if updater is set
+	if globalLoggerUpdater != nil {
+		ctx = globalLoggerUpdater.UpdateContext(ctx, func(c LoggerContext) LoggerContext {
+			return c.Str("AuthMethod", method)
+		})
+	}
+
+	return context.WithValue(ctx, authMethodField, method)
+}
+
 func GetRequestID(ctx context.Context) (string, error) {
 	ridf := ctx.Value(requestIDField)
 	if ridf == nil {
@@ -0,0 +1,37 @@
+package reqctx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+)
+
+func TestSetActor(t *testing.T) {
+	ctx := context.Background()
+
+	valid := []*actor.Actor{
+		{ID: 1},
+		{StringID: "ext-42"},
+		actor.NewAnonymous(),
+		actor.NewSystem("cleanup"),
+	}
+
+	for _, act := range valid {
+		got, err := SetActor(ctx, act)
+		require.NoError(t, err)
+
+		stored, err := GetActor(got)
+		require.NoError(t, err)
+		assert.Same(t, act, stored)
+	}
+
+	_, err := SetActor(ctx, &actor.Actor{})
+	require.Error(t, err)
+
+	_, err = SetActor(ctx, &actor.Actor{Kind: actor.KindService})
+	require.Error(t, err)
+}
@@ -0,0 +1,122 @@
+package reqctx
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+)
+
+var (
+	// ErrImpersonationDenied is returned by Impersonate when the policy rejects it
+	ErrImpersonationDenied = fmt.Errorf("impersonation denied")
+)
+
+// ImpersonationPolicy decides whether real actor may act on behalf of effective
+// one, a non-nil error denies impersonation
+type ImpersonationPolicy func(ctx context.Context, real, effective ds.Actor) error
+
+var globalImpersonationPolicy ImpersonationPolicy // nil by default, every impersonation is denied
+
+// SetImpersonationPolicy sets the policy checked by Impersonate, e.g.
+// (*authz.Engine).ImpersonationPolicy. This should be called once during
+// application initialization.
+func SetImpersonationPolicy(p ImpersonationPolicy) {
+	globalImpersonationPolicy = p
+}
+
+// ImpersonationObserver is notified of impersonation decisions, err is nil if
+// impersonation is granted. It is used for audit of impersonations.
+type ImpersonationObserver func(ctx context.Context, real, effective ds.Actor, err error)
+
+var globalImpersonationObserver ImpersonationObserver // nil by default
+
+// SetImpersonationObserver sets the observer of Impersonate, e.g.
+// serviceauth.AuditImpersonation (set by serviceauth.SetAuditor). This should
+// be called once during application initialization.
+func SetImpersonationObserver(o ImpersonationObserver) {
+	globalImpersonationObserver = o
+}
+
+// Impersonate stores effective actor as the actor of ctx (GetActor returns it)
+// and real actor as the one acting on its behalf, e.g. a support agent or a job
+// triggered by the user. Besides fields of SetActor the logger context gets
+// RealActorKind, RealActorUID and RealActorSID.
+//
+// The impersonation is checked by the policy set by SetImpersonationPolicy,
+// without the policy it is denied. Granted and denied impersonations are
+// reported to the observer set by SetImpersonationObserver.
+func Impersonate(ctx context.Context, real, effective ds.Actor) (context.Context, error) {
+	impCtx, err := impersonate(ctx, real, effective)
+
+	if observer := globalImpersonationObserver; observer != nil {
+		if impCtx != nil {
+			ctx = impCtx
+		}
+
+		observer(ctx, real, effective, err)
+	}
+
+	return impCtx, err
+}
+
+func impersonate(ctx context.Context, real, effective ds.Actor) (context.Context, error) {
+	if err := validateActor(real); err != nil {
+		return nil, errors.Wrap(err, "real actor")
+	}
+
+	if actor.KindOf(real) == actor.KindAnonymous {
+		return nil, errors.Wrap(ErrImpersonationDenied, "anonymous real actor")
+	}
+
+	if err := validateActor(effective); err != nil {
+		return nil, errors.Wrap(err, "effective actor")
+	}
+
+	policy := globalImpersonationPolicy
+	if policy == nil {
+		return nil, errors.Wrap(ErrImpersonationDenied, "no impersonation policy")
+	}
+
+	if err := policy(ctx, real, effective); err != nil {
+		if errors.Is(err, ErrImpersonationDenied) {
+			return nil, err
+		}
+
+		return nil, errors.Wrapf(ErrImpersonationDenied, "%v", err)
+	}
+
+	ctx, err := SetActor(ctx, effective)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update logger context if updater is set
+	if globalLoggerUpdater != nil {
+		ctx = globalLoggerUpdater.UpdateContext(ctx, func(c LoggerContext) LoggerContext {
+			return actorFields(c, "RealActor", real)
+		})
+	}
+
+	return context.WithValue(ctx, realActorField, real), nil
+}
+
+// GetRealActor returns the actor acting on behalf of the actor of ctx, the
+// actor itself if there is no impersonation
+func GetRealActor(ctx context.Context) (ds.Actor, error) {
+	if real, ok := ctx.Value(realActorField).(ds.Actor); ok {
+		return real, nil
+	}
+
+	return GetActor(ctx)
+}
+
+// IsImpersonated reports whether the actor of ctx is impersonated
+func IsImpersonated(ctx context.Context) bool {
+	_, ok := ctx.Value(realActorField).(ds.Actor)
+
+	return ok
+}
@@ -0,0 +1,69 @@
+package reqctx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/ds"
+	"github.com/Educentr/go-project-starter-runtime/pkg/model/actor"
+)
+
+func TestImpersonate(t *testing.T) {
+	ctx := context.Background()
+	support := &actor.Actor{ID: 1}
+	user := &actor.Actor{ID: 2}
+
+	_, err := Impersonate(ctx, support, user)
+	require.ErrorIs(t, err, ErrImpersonationDenied, "denied without policy")
+
+	SetImpersonationPolicy(func(_ context.Context, real, _ ds.Actor) error {
+		if real.GetID() != support.ID {
+			return assert.AnError
+		}
+
+		return nil
+	})
+	t.Cleanup(func() { SetImpersonationPolicy(nil) })
+
+	var decisions []error
+
+	SetImpersonationObserver(func(_ context.Context, _, _ ds.Actor, err error) {
+		decisions = append(decisions, err)
+	})
+	t.Cleanup(func() { SetImpersonationObserver(nil) })
+
+	_, err = Impersonate(ctx, user, support)
+	require.ErrorIs(t, err, ErrImpersonationDenied)
+
+	_, err = Impersonate(ctx, actor.NewAnonymous(), user)
+	require.ErrorIs(t, err, ErrImpersonationDenied)
+
+	ctx, err = Impersonate(ctx, support, user)
+	require.NoError(t, err)
+	assert.True(t, IsImpersonated(ctx))
+
+	require.Len(t, decisions, 3)
+	require.ErrorIs(t, decisions[0], ErrImpersonationDenied)
+	require.ErrorIs(t, decisions[1], ErrImpersonationDenied)
+	require.NoError(t, decisions[2])
+
+	act, err := GetActor(ctx)
+	require.NoError(t, err)
+	assert.Same(t, user, act)
+
+	real, err := GetRealActor(ctx)
+	require.NoError(t, err)
+	assert.Same(t, support, real)
+
+	// SetActor ends impersonation
+	ctx, err = SetActor(ctx, user)
+	require.NoError(t, err)
+	assert.False(t, IsImpersonated(ctx))
+
+	real, err = GetRealActor(ctx)
+	require.NoError(t, err)
+	assert.Same(t, user, real)
+}
@@ -0,0 +1,20 @@
+package reqctx
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SetSpanFields adds TraceID and SpanID of the span in ctx to the logger
+// context. Call it after a span is started, e.g. by tracing.Middleware.
+func SetSpanFields(ctx context.Context) context.Context {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() || globalLoggerUpdater == nil {
+		return ctx
+	}
+
+	return globalLoggerUpdater.UpdateContext(ctx, func(c LoggerContext) LoggerContext {
+		return c.Str("TraceID", sc.TraceID().String()).Str("SpanID", sc.SpanID().String())
+	})
+}
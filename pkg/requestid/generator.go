@@ -0,0 +1,74 @@
+package requestid
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	randv2 "math/rand/v2"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Generator returns a new request ID
+type Generator func() string
+
+// UUIDv7 generates time ordered UUIDs (RFC 9562), the default generator
+func UUIDv7() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString()
+	}
+
+	return id.String()
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID generates lexicographically sortable 26 character IDs: 48 bit
+// millisecond timestamp and 80 random bits in Crockford's base32
+func ULID() string {
+	var id [16]byte
+
+	ms := uint64(time.Now().UnixMilli())
+	binary.BigEndian.PutUint16(id[0:], uint16(ms>>32))
+	binary.BigEndian.PutUint32(id[2:], uint32(ms))
+	_, _ = rand.Read(id[6:])
+
+	return encodeULID(id)
+}
+
+// encodeULID encodes 128 bits as 26 characters of 5 bits, the first one has 3 bits
+func encodeULID(id [16]byte) string {
+	bit := func(p int) byte {
+		if p < 0 {
+			return 0
+		}
+
+		return (id[p/8] >> (7 - p%8)) & 1
+	}
+
+	var dst [26]byte
+
+	for i := range dst {
+		var v byte
+
+		for p := i*5 - 2; p < i*5+3; p++ {
+			v = v<<1 | bit(p)
+		}
+
+		dst[i] = crockford[v]
+	}
+
+	return string(dst[:])
+}
+
+// Random generates 16 hex characters from a non-cryptographic source, the
+// cheapest generator when IDs needn't be sortable or unguessable
+func Random() string {
+	var id [8]byte
+
+	binary.BigEndian.PutUint64(id[:], randv2.Uint64())
+
+	return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,228 @@
+// Package requestid assigns correlation IDs to requests and propagates them.
+//
+// An incoming ID is taken from the first configured header holding a valid
+// value, then from the trace ID of the OTel span or the traceparent header,
+// otherwise a new one is generated. The ID is stored by reqctx.SetRequestID
+// (so it is added to the logger context), echoed in responses and injected
+// into outgoing HTTP requests and gRPC metadata.
+//
+//	ids := requestid.New(requestid.Config{})
+//	handler = ids.Middleware(handler)
+//	client.Transport = ids.Transport(http.DefaultTransport)
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
+)
+
+// Default headers
+const (
+	HeaderRequestID     = "X-Request-Id"
+	HeaderCorrelationID = "X-Correlation-Id"
+	HeaderTraceparent   = "traceparent"
+)
+
+const defaultMaxLength = 64
+
+// Config of the propagator
+type Config struct {
+	// Generator of new IDs, UUIDv7 by default
+	Generator Generator
+
+	// Headers with incoming IDs in order of preference, X-Request-Id and X-Correlation-Id by default
+	Headers []string
+
+	// Header of responses and outgoing requests, X-Request-Id by default
+	Header string
+
+	// MaxLength of incoming IDs, 64 by default. Longer IDs are ignored.
+	MaxLength int
+
+	// Validate checks incoming IDs, by default letters, digits and "-_.:" are allowed
+	Validate func(id string) bool
+
+	// IgnoreTraceparent disables the fallback to the trace ID
+	IgnoreTraceparent bool
+}
+
+// Propagator extracts, generates and injects request IDs
+type Propagator struct {
+	cfg Config
+}
+
+// New creates the propagator
+func New(cfg Config) *Propagator {
+	if cfg.Generator == nil {
+		cfg.Generator = UUIDv7
+	}
+
+	if len(cfg.Headers) == 0 {
+		cfg.Headers = []string{HeaderRequestID, HeaderCorrelationID}
+	}
+
+	if cfg.Header == "" {
+		cfg.Header = HeaderRequestID
+	}
+
+	if cfg.MaxLength <= 0 {
+		cfg.MaxLength = defaultMaxLength
+	}
+
+	if cfg.Validate == nil {
+		cfg.Validate = ValidChars
+	}
+
+	return &Propagator{cfg: cfg}
+}
+
+// ValidChars allows letters, digits and "-_.:"
+func ValidChars(id string) bool {
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *Propagator) valid(id string) bool {
+	return id != "" && len(id) <= p.cfg.MaxLength && p.cfg.Validate(id)
+}
+
+// Extract returns the request ID of an incoming request with header values
+// returned by get, "" if there is none
+func (p *Propagator) Extract(ctx context.Context, get func(key string) string) string {
+	for _, h := range p.cfg.Headers {
+		if id := strings.TrimSpace(get(h)); p.valid(id) {
+			return id
+		}
+	}
+
+	if p.cfg.IgnoreTraceparent {
+		return ""
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+
+	return traceIDFromTraceparent(get(HeaderTraceparent))
+}
+
+// traceIDFromTraceparent parses "version-traceid-spanid-flags" of W3C Trace Context
+func traceIDFromTraceparent(header string) string {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return ""
+	}
+
+	traceID, err := trace.TraceIDFromHex(parts[1])
+	if err != nil {
+		return ""
+	}
+
+	return traceID.String()
+}
+
+// Ensure returns ctx with the request ID: the one already in ctx, id if valid
+// or a new one
+func (p *Propagator) Ensure(ctx context.Context, id string) (context.Context, string) {
+	if current, err := reqctx.GetRequestID(ctx); err == nil && current != "" {
+		return ctx, current
+	}
+
+	if !p.valid(id) {
+		id = p.cfg.Generator()
+	}
+
+	ctx, err := reqctx.SetRequestID(ctx, id)
+	if err != nil {
+		// the generator returned ""
+		return ctx, ""
+	}
+
+	return ctx, id
+}
+
+// FromHTTP returns the context of r with the request ID
+func (p *Propagator) FromHTTP(r *http.Request) (context.Context, string) {
+	return p.Ensure(r.Context(), p.Extract(r.Context(), r.Header.Get))
+}
+
+// FromGRPC returns ctx with the request ID of incoming metadata, metadata.MD
+// can be passed as is
+func (p *Propagator) FromGRPC(ctx context.Context, md map[string][]string) (context.Context, string) {
+	return p.Ensure(ctx, p.Extract(ctx, func(key string) string {
+		if values := md[strings.ToLower(key)]; len(values) > 0 {
+			return values[0]
+		}
+
+		return ""
+	}))
+}
+
+// Middleware stores the request ID in the request context and sets the response header
+func (p *Propagator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, id := p.FromHTTP(r)
+		if id != "" {
+			w.Header().Set(p.cfg.Header, id)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Transport injects the request ID of the request context into outgoing requests
+func (p *Propagator) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return roundTripper(func(r *http.Request) (*http.Response, error) {
+		id, err := reqctx.GetRequestID(r.Context())
+		if err != nil || id == "" || r.Header.Get(p.cfg.Header) != "" {
+			return base.RoundTrip(r)
+		}
+
+		r = r.Clone(r.Context())
+		r.Header.Set(p.cfg.Header, id)
+
+		return base.RoundTrip(r)
+	})
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// Metadata returns gRPC metadata with the request ID of ctx for outgoing
+// calls (metadata.AppendToOutgoingContext) and response headers
+// (grpc.SetHeader), nil if ctx has no ID
+func (p *Propagator) Metadata(ctx context.Context) map[string]string {
+	id, err := reqctx.GetRequestID(ctx)
+	if err != nil || id == "" {
+		return nil
+	}
+
+	return map[string]string{strings.ToLower(p.cfg.Header): id}
+}
+
+// InjectGRPC sets the request ID of ctx in md, metadata.MD can be passed as is
+func (p *Propagator) InjectGRPC(ctx context.Context, md map[string][]string) {
+	for key, value := range p.Metadata(ctx) {
+		md[key] = []string{value}
+	}
+}
@@ -0,0 +1,116 @@
+package requestid
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Educentr/go-project-starter-runtime/pkg/reqctx"
+)
+
+func TestGenerators(t *testing.T) {
+	id, err := uuid.Parse(UUIDv7())
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Version(7), id.Version())
+
+	ulid := ULID()
+	assert.Len(t, ulid, 26)
+	assert.Less(t, ulid[0], byte('8'), "first character has 3 bits")
+	assert.Equal(t, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", encodeULID([16]byte{
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	}))
+
+	assert.Len(t, Random(), 16)
+	assert.NotEqual(t, Random(), Random())
+}
+
+func TestPropagator_Extract(t *testing.T) {
+	p := New(Config{MaxLength: 16})
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   string
+	}{
+		{"request id", http.Header{"X-Request-Id": {"abc-1"}, "X-Correlation-Id": {"corr"}}, "abc-1"},
+		{"invalid falls through", http.Header{"X-Request-Id": {"bad id!"}, "X-Correlation-Id": {"corr"}}, "corr"},
+		{"too long", http.Header{"X-Request-Id": {strings.Repeat("a", 17)}}, ""},
+		{"traceparent", http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"zero trace id", http.Header{"Traceparent": {"00-00000000000000000000000000000000-00f067aa0ba902b7-01"}}, ""},
+		{"none", http.Header{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, p.Extract(ctx, tt.header.Get))
+		})
+	}
+}
+
+func TestPropagator_HTTP(t *testing.T) {
+	p := New(Config{Generator: func() string { return "generated" }})
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get(HeaderRequestID)))
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: p.Transport(nil)}
+
+	var forwarded string
+
+	handler := p.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, upstream.URL, nil)
+		require.NoError(t, err)
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		forwarded = string(body)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "generated", w.Header().Get(HeaderRequestID))
+	assert.Equal(t, "generated", forwarded)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HeaderCorrelationID, "incoming")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, "incoming", w.Header().Get(HeaderRequestID))
+	assert.Equal(t, "incoming", forwarded)
+}
+
+func TestPropagator_GRPC(t *testing.T) {
+	p := New(Config{})
+
+	ctx, id := p.FromGRPC(context.Background(), map[string][]string{"x-request-id": {"grpc-1"}})
+	assert.Equal(t, "grpc-1", id)
+
+	stored, err := reqctx.GetRequestID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "grpc-1", stored)
+
+	md := map[string][]string{}
+	p.InjectGRPC(ctx, md)
+	assert.Equal(t, []string{"grpc-1"}, md["x-request-id"])
+
+	// IDs already in the context are kept
+	_, id = p.Ensure(ctx, "other")
+	assert.Equal(t, "grpc-1", id)
+}